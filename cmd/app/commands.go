@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"os/signal"
+	"syscall"
+
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/config"
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/db"
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/scheduler"
+	"go.uber.org/zap"
+)
+
+// runCommand runs a one-off command instead of the service and returns its
+// exit code.
+func runCommand(command string, cfg *config.Config, log *zap.Logger) int {
+	switch command {
+	case "healthcheck":
+		// the container health check runs this every few seconds, so it skips all setup
+		return runHealthcheck(cfg.MetricsPort, log)
+	case "reconcile", "parked-cancellations", "cancel-dry-run":
+	default:
+		log.Error("unknown command", zap.String("command", command))
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	database, err := db.NewConnection(cfg.DatabaseDSN)
+	if err != nil {
+		log.Error("failed to connect to database", zap.Error(err))
+		return 1
+	}
+	defer database.Close()
+
+	if err := database.HealthCheck(ctx); err != nil {
+		log.Error("database health check failed", zap.Error(err))
+		return 1
+	}
+
+	switch command {
+	case "reconcile":
+		return runReconcile(ctx, scheduler.NewReconciler(database, 0, log), log)
+	case "parked-cancellations":
+		return runParkedCancellations(ctx, database, log)
+	default: // cancel-dry-run
+		cancelScheduler, _, err := newCancelScheduler(cfg, database, log)
+		if err != nil {
+			log.Error("failed to set up cancel scheduler", zap.Error(err))
+			return 1
+		}
+		return runCancelDryRun(ctx, cancelScheduler, log)
+	}
+}
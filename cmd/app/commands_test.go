@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/config"
+	"go.uber.org/zap"
+)
+
+func TestRunCommand_Healthcheck(t *testing.T) {
+	ready := http.StatusOK
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/readyz" {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(ready)
+	}))
+	defer service.Close()
+
+	u, err := url.Parse(service.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// no database: the health check must not need one
+	cfg := &config.Config{MetricsPort: u.Port(), DatabaseDSN: "postgres://invalid:0/none"}
+
+	if code := runCommand("healthcheck", cfg, zap.NewNop()); code != 0 {
+		t.Errorf("runCommand(healthcheck) with the service ready = %d, want 0", code)
+	}
+	ready = http.StatusServiceUnavailable
+	if code := runCommand("healthcheck", cfg, zap.NewNop()); code != 1 {
+		t.Errorf("runCommand(healthcheck) with the service not ready = %d, want 1", code)
+	}
+}
+
+func TestRunCommand_Unknown(t *testing.T) {
+	if code := runCommand("migrate", &config.Config{}, zap.NewNop()); code != 2 {
+		t.Errorf("runCommand(migrate) = %d, want 2", code)
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"context"
+
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/scheduler"
+	"go.uber.org/zap"
+)
+
+// runCancelDryRun logs what one cancellation cycle would do with the
+// configured strategy, for the `cancel-dry-run` subcommand. Nothing is
+// written and no leadership is needed.
+func runCancelDryRun(ctx context.Context, s *scheduler.Scheduler, log *zap.Logger) int {
+	if _, err := s.DryRun(ctx); err != nil {
+		log.Error("dry run failed", zap.Error(err))
+		return 1
+	}
+	return 0
+}
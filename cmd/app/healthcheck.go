@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// runHealthcheck probes /readyz of the service running next to it, for the
+// `healthcheck` subcommand; the image has no curl to do it.
+func runHealthcheck(port string, log *zap.Logger) int {
+	client := &http.Client{Timeout: 2 * time.Second}
+
+	resp, err := client.Get("http://localhost:" + port + "/readyz")
+	if err != nil {
+		log.Error("health check failed", zap.Error(err))
+		return 1
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Error("service not ready", zap.Int("status", resp.StatusCode))
+		return 1
+	}
+	return 0
+}
@@ -2,16 +2,27 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/config"
 	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/db"
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/domain"
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/events"
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/healthcheck"
 	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/logger"
 	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/repository"
 	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/scheduler"
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/tracing"
 	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/transport"
 	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/usecase"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/health"
 )
 
 func main() {
@@ -21,41 +32,250 @@ func main() {
 	logger.SetGlobal(log)
 	defer logger.Sync()
 
+	if len(os.Args) > 1 {
+		code := runCommand(os.Args[1], cfg, log)
+		logger.Sync()
+		os.Exit(code)
+	}
+
 	log.Info("starting application",
 		zap.String("grpc_port", cfg.GRPCPort),
+		zap.String("admin_grpc_addr", cfg.AdminGRPCAddr),
+		zap.String("metrics_port", cfg.MetricsPort),
+		zap.String("tracing_exporter", cfg.TracingExporter),
 		zap.Bool("cancel_scheduler_enabled", cfg.CancelSchedulerEnabled),
 		zap.Int("cancel_period_min", cfg.CancelPeriodMin),
+		zap.Bool("cancel_dry_run", cfg.CancelDryRun),
+		zap.Int("hold_ttl_min", cfg.HoldTTLMin),
 	)
 
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter: cfg.TracingExporter,
+		Endpoint: cfg.TracingEndpoint,
+		Service:  "balance-service",
+	})
+	if err != nil {
+		log.Fatal("failed to set up tracing", zap.Error(err))
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Error("failed to flush traces", zap.Error(err))
+		}
+	}()
+
 	database, err := db.NewConnection(cfg.DatabaseDSN)
 	if err != nil {
 		log.Fatal("failed to connect to database", zap.Error(err))
 	}
 	defer database.Close()
 
-	ctx := context.Background()
+	// canceled on SIGTERM or SIGINT, which starts the shutdown
+	ctx, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stopSignals()
+
 	if err := database.HealthCheck(ctx); err != nil {
 		log.Fatal("database health check failed", zap.Error(err))
 	}
 
+	// also serves manual cancellations when the background job is disabled
+	cancelScheduler, leader, err := newCancelScheduler(cfg, database, log)
+	if err != nil {
+		log.Fatal("failed to set up cancel scheduler", zap.Error(err))
+	}
+
+	// background work stops with ctx; shutdown waits for it
+	var workers sync.WaitGroup
+	background := func(run func(context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(ctx)
+		}()
+	}
+
+	balanceEvents := events.NewBroker(database, log)
+	background(balanceEvents.Run)
+
 	repo := repository.NewBalanceRepository(database)
-	balanceService := usecase.NewBalanceUsecase(repo)
+	balanceService := usecase.NewBalanceUsecase(repo, balanceEvents, cancelScheduler, time.Duration(cfg.HoldTTLMin)*time.Minute)
 
-	if cfg.CancelSchedulerEnabled {
-		cancelScheduler := scheduler.NewCancelScheduler(
+	holdExpirer := scheduler.NewHoldExpirer(
+		database,
+		time.Duration(cfg.HoldExpiryPeriodMin)*time.Minute,
+		log,
+	)
+	background(holdExpirer.Run)
+
+	if cfg.SnapshotsEnabled {
+		snapshotter := scheduler.NewBalanceSnapshotter(
 			database,
-			time.Duration(cfg.CancelPeriodMin)*time.Minute,
+			time.Duration(cfg.SnapshotPeriodMin)*time.Minute,
 			log,
 		)
-		go cancelScheduler.Run(ctx)
+		background(snapshotter.Run)
+	}
+
+	if cfg.ReconcileEnabled {
+		reconciler := scheduler.NewReconciler(
+			database,
+			time.Duration(cfg.ReconcilePeriodMin)*time.Minute,
+			log,
+		)
+		background(reconciler.Run)
+	}
+
+	// the leader keeps its lock until the scheduler has finished its cycle
+	leaderCtx, stopLeader := context.WithCancel(context.Background())
+	leaderDone := make(chan struct{})
+	if cfg.CancelSchedulerEnabled {
+		go func() {
+			defer close(leaderDone)
+			leader.Run(leaderCtx)
+		}()
+		background(cancelScheduler.Run)
 		log.Info("cancel scheduler started")
 	} else {
+		close(leaderDone)
 		log.Info("cancel scheduler disabled")
 	}
 
-	server := transport.NewGRPCServer(balanceService)
+	adminService := usecase.NewAdminUsecase(cancelScheduler)
 
-	if err := transport.Serve(server, cfg.GRPCPort); err != nil {
-		log.Fatal("failed to serve", zap.Error(err))
+	var schedulerHealth healthcheck.Scheduler
+	if cfg.CancelSchedulerEnabled {
+		schedulerHealth = cancelScheduler
 	}
+	healthServer := health.NewServer()
+	healthManager := healthcheck.NewManager(healthServer, database, schedulerHealth, healthcheck.Config{
+		Period:          time.Duration(cfg.HealthCheckPeriodSec) * time.Second,
+		SchedulerMaxAge: time.Duration(cfg.HealthSchedulerMaxMin) * time.Minute,
+		Services:        transport.DatabaseServices,
+	}, log)
+	// statuses are known before the first request arrives
+	healthManager.Check(ctx)
+	background(healthManager.Run)
+
+	prometheus.MustRegister(database.StatsCollector())
+	httpServer := transport.NewHTTPServer(cfg.MetricsPort, healthManager)
+
+	server := transport.NewGRPCServer(balanceService, healthServer)
+	adminServer := transport.NewAdminGRPCServer(adminService)
+
+	serveErr := make(chan error, 3)
+	go func() {
+		serveErr <- transport.ServeHTTP(httpServer)
+	}()
+	go func() {
+		serveErr <- transport.Serve(server, ":"+cfg.GRPCPort)
+	}()
+	go func() {
+		serveErr <- transport.Serve(adminServer, cfg.AdminGRPCAddr)
+	}()
+
+	select {
+	case <-ctx.Done():
+		log.Info("shutdown signal received")
+	case err := <-serveErr:
+		log.Error("server failed, shutting down", zap.Error(err))
+		stopSignals()
+	}
+
+	// readiness fails first, and load balancers get the delay to stop sending
+	// traffic before the servers stop accepting it
+	healthManager.Shutdown()
+	if cfg.ShutdownDelaySec > 0 {
+		log.Info("waiting before stopping the servers", zap.Int("delay_sec", cfg.ShutdownDelaySec))
+		time.Sleep(time.Duration(cfg.ShutdownDelaySec) * time.Second)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeoutSec)*time.Second)
+	defer cancel()
+
+	transport.Stop(shutdownCtx, server)
+	transport.Stop(shutdownCtx, adminServer)
+	// probes are answered until the RPCs have drained
+	transport.StopHTTP(shutdownCtx, httpServer)
+
+	// a cancellation batch in progress commits before the scheduler returns
+	if !waitFor(shutdownCtx, workers.Wait) {
+		log.Warn("background workers did not stop in time")
+	}
+
+	stopLeader()
+	if !waitFor(shutdownCtx, func() { <-leaderDone }) {
+		log.Warn("leader lock was not released in time")
+	}
+
+	log.Info("shutdown complete")
+}
+
+// waitFor runs wait and reports whether it returned before ctx was done.
+func waitFor(ctx context.Context, wait func()) bool {
+	done := make(chan struct{})
+	go func() {
+		wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// newCancelScheduler builds the cancel scheduler and the leader election it
+// runs under from cfg.
+func newCancelScheduler(cfg *config.Config, database *db.DB, log *zap.Logger) (*scheduler.Scheduler, *scheduler.LeaderElector, error) {
+	strategy, err := scheduler.NewSelectionStrategy(scheduler.StrategyConfig{
+		Name:      cfg.CancelStrategy,
+		OlderThan: time.Duration(cfg.CancelOlderThanMin) * time.Minute,
+		Source:    domain.Source(cfg.CancelSource),
+		State:     domain.State(cfg.CancelState),
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid cancel strategy: %w", err)
+	}
+
+	timetable, err := scheduler.NewTimetable(scheduler.TimetableConfig{
+		Period:    time.Duration(cfg.CancelPeriodMin) * time.Minute,
+		Cron:      cfg.CancelCron,
+		Blackouts: cfg.CancelBlackout,
+		Location:  cfg.CancelTimezone,
+		Jitter:    time.Duration(cfg.CancelJitterSec) * time.Second,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid cancel schedule: %w", err)
+	}
+
+	leader := scheduler.NewLeaderElector(
+		database,
+		scheduler.AdvisoryLockKey,
+		time.Duration(cfg.LeaderHeartbeatSec)*time.Second,
+		log,
+	)
+
+	cancelScheduler := scheduler.NewCancelScheduler(
+		database,
+		timetable,
+		strategy,
+		leader,
+		scheduler.RetryPolicy{
+			MaxAttempts: cfg.CancelRetryMaxAttempts,
+			BaseDelay:   time.Duration(cfg.CancelRetryBaseMin) * time.Minute,
+			MaxDelay:    time.Duration(cfg.CancelRetryMaxMin) * time.Minute,
+		},
+		scheduler.BatchConfig{
+			Size:          cfg.CancelBatchSize,
+			Budget:        time.Duration(cfg.CancelCycleBudgetSec) * time.Second,
+			Workers:       cfg.CancelWorkers,
+			MaxCandidates: cfg.CancelCycleMaxCandidates,
+		},
+		cfg.CancelDryRun,
+		log,
+	)
+	return cancelScheduler, leader, nil
 }
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/db"
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/scheduler"
+	"go.uber.org/zap"
+)
+
+// parkedCancellationsLimit bounds the output of the `parked-cancellations` subcommand.
+const parkedCancellationsLimit = 1000
+
+// runParkedCancellations lists cancellations that ran out of retries and
+// need manual review, for the `parked-cancellations` subcommand.
+func runParkedCancellations(ctx context.Context, database *db.DB, log *zap.Logger) int {
+	parked, err := scheduler.ParkedCancellations(ctx, database, parkedCancellationsLimit)
+	if err != nil {
+		log.Error("failed to list parked cancellations", zap.Error(err))
+		return 1
+	}
+
+	for _, p := range parked {
+		log.Info("parked cancellation",
+			zap.Int64("op_id", p.OperationID),
+			zap.String("tx_id", p.TxID),
+			zap.Int("attempts", p.Attempts),
+			zap.String("last_reason", string(p.LastReason)),
+			zap.Timep("parked_at", p.ParkedAt))
+	}
+	log.Info("parked cancellations listed", zap.Int("count", len(parked)))
+	return 0
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/scheduler"
+	"go.uber.org/zap"
+)
+
+// reconciler is the part of *scheduler.Reconciler the subcommand uses.
+type reconciler interface {
+	Reconcile(ctx context.Context) (*scheduler.ReconciliationReport, error)
+}
+
+// runReconcile performs a single reconciliation for the `reconcile` subcommand.
+// The exit code is 0 when balances match the ledger, 1 on drift or failure.
+func runReconcile(ctx context.Context, r reconciler, log *zap.Logger) int {
+	report, err := r.Reconcile(ctx)
+	if err != nil {
+		log.Error("reconciliation failed", zap.Error(err))
+		return 1
+	}
+
+	if len(report.Drifts) > 0 {
+		return 1
+	}
+	return 0
+}
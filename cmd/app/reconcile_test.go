@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/scheduler"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+type fakeReconciler struct {
+	report *scheduler.ReconciliationReport
+	err    error
+}
+
+func (f fakeReconciler) Reconcile(ctx context.Context) (*scheduler.ReconciliationReport, error) {
+	return f.report, f.err
+}
+
+func TestRunReconcile_ExitCode(t *testing.T) {
+	tests := []struct {
+		name       string
+		reconciler fakeReconciler
+		expected   int
+	}{
+		{
+			name:       "balances match",
+			reconciler: fakeReconciler{report: &scheduler.ReconciliationReport{AccountsChecked: 2}},
+			expected:   0,
+		},
+		{
+			name: "drift",
+			reconciler: fakeReconciler{report: &scheduler.ReconciliationReport{
+				AccountsChecked: 2,
+				Drifts:          []scheduler.AccountDrift{{StoredBalance: decimal.NewFromInt(1)}},
+			}},
+			expected: 1,
+		},
+		{
+			name:       "failure",
+			reconciler: fakeReconciler{err: errors.New("connection refused")},
+			expected:   1,
+		},
+		{
+			name:       "another replica is reconciling",
+			reconciler: fakeReconciler{err: scheduler.ErrReconciliationBusy},
+			expected:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := runReconcile(context.Background(), tt.reconciler, zap.NewNop()); code != tt.expected {
+				t.Errorf("runReconcile() = %d, want %d", code, tt.expected)
+			}
+		})
+	}
+}
@@ -7,11 +7,42 @@ import (
 )
 
 type Config struct {
-	DatabaseDSN            string `env:"DATABASE_DSN,required"`
-	GRPCPort               string `env:"GRPC_PORT" envDefault:"8080"`
-	CancelPeriodMin        int    `env:"CANCEL_PERIOD_MIN" envDefault:"5"`
-	CancelSchedulerEnabled bool   `env:"CANCEL_SCHEDULER_ENABLED" envDefault:"true"`
-	LogLevel               string `env:"LOG_LEVEL" envDefault:"info"`
+	DatabaseDSN              string   `env:"DATABASE_DSN,required"`
+	GRPCPort                 string   `env:"GRPC_PORT" envDefault:"8080"`
+	AdminGRPCAddr            string   `env:"ADMIN_GRPC_ADDR" envDefault:"127.0.0.1:8081"`
+	MetricsPort              string   `env:"METRICS_PORT" envDefault:"9090"`
+	TracingExporter          string   `env:"TRACING_EXPORTER" envDefault:"none"`
+	TracingEndpoint          string   `env:"TRACING_ENDPOINT" envDefault:"localhost:4317"`
+	CancelPeriodMin          int      `env:"CANCEL_PERIOD_MIN" envDefault:"5"`
+	CancelSchedulerEnabled   bool     `env:"CANCEL_SCHEDULER_ENABLED" envDefault:"true"`
+	CancelDryRun             bool     `env:"CANCEL_DRY_RUN" envDefault:"false"`
+	CancelCron               string   `env:"CANCEL_CRON"`
+	CancelBlackout           []string `env:"CANCEL_BLACKOUT" envSeparator:","`
+	CancelTimezone           string   `env:"CANCEL_TIMEZONE" envDefault:"UTC"`
+	CancelJitterSec          int      `env:"CANCEL_JITTER_SEC" envDefault:"0"`
+	LeaderHeartbeatSec       int      `env:"LEADER_HEARTBEAT_SEC" envDefault:"5"`
+	CancelStrategy           string   `env:"CANCEL_STRATEGY" envDefault:"odd_latest"`
+	CancelOlderThanMin       int      `env:"CANCEL_OLDER_THAN_MIN" envDefault:"60"`
+	CancelSource             string   `env:"CANCEL_SOURCE"`
+	CancelState              string   `env:"CANCEL_STATE"`
+	CancelRetryMaxAttempts   int      `env:"CANCEL_RETRY_MAX_ATTEMPTS" envDefault:"5"`
+	CancelRetryBaseMin       int      `env:"CANCEL_RETRY_BASE_MIN" envDefault:"5"`
+	CancelRetryMaxMin        int      `env:"CANCEL_RETRY_MAX_MIN" envDefault:"1440"`
+	CancelBatchSize          int      `env:"CANCEL_BATCH_SIZE" envDefault:"10"`
+	CancelCycleBudgetSec     int      `env:"CANCEL_CYCLE_BUDGET_SEC" envDefault:"60"`
+	CancelWorkers            int      `env:"CANCEL_WORKERS" envDefault:"1"`
+	CancelCycleMaxCandidates int      `env:"CANCEL_CYCLE_MAX_CANDIDATES" envDefault:"1000"`
+	HoldTTLMin               int      `env:"HOLD_TTL_MIN" envDefault:"30"`
+	HoldExpiryPeriodMin      int      `env:"HOLD_EXPIRY_PERIOD_MIN" envDefault:"1"`
+	SnapshotPeriodMin        int      `env:"SNAPSHOT_PERIOD_MIN" envDefault:"1440"`
+	SnapshotsEnabled         bool     `env:"SNAPSHOTS_ENABLED" envDefault:"true"`
+	ReconcilePeriodMin       int      `env:"RECONCILE_PERIOD_MIN" envDefault:"60"`
+	ReconcileEnabled         bool     `env:"RECONCILE_ENABLED" envDefault:"true"`
+	ShutdownDelaySec         int      `env:"SHUTDOWN_DELAY_SEC" envDefault:"0"`
+	ShutdownTimeoutSec       int      `env:"SHUTDOWN_TIMEOUT_SEC" envDefault:"25"`
+	HealthCheckPeriodSec     int      `env:"HEALTH_CHECK_PERIOD_SEC" envDefault:"10"`
+	HealthSchedulerMaxMin    int      `env:"HEALTH_SCHEDULER_MAX_MIN" envDefault:"15"`
+	LogLevel                 string   `env:"LOG_LEVEL" envDefault:"info"`
 }
 
 func Load() *Config {
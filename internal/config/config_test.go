@@ -15,6 +15,22 @@ func TestConfig_Defaults(t *testing.T) {
 		t.Errorf("GRPCPort = %v, want 8080", cfg.GRPCPort)
 	}
 
+	if cfg.AdminGRPCAddr != "127.0.0.1:8081" {
+		t.Errorf("AdminGRPCAddr = %v, want 127.0.0.1:8081", cfg.AdminGRPCAddr)
+	}
+
+	if cfg.MetricsPort != "9090" {
+		t.Errorf("MetricsPort = %v, want 9090", cfg.MetricsPort)
+	}
+
+	if cfg.TracingExporter != "none" {
+		t.Errorf("TracingExporter = %v, want none", cfg.TracingExporter)
+	}
+
+	if cfg.TracingEndpoint != "localhost:4317" {
+		t.Errorf("TracingEndpoint = %v, want localhost:4317", cfg.TracingEndpoint)
+	}
+
 	if cfg.CancelPeriodMin != 5 {
 		t.Errorf("CancelPeriodMin = %v, want 5", cfg.CancelPeriodMin)
 	}
@@ -23,6 +39,104 @@ func TestConfig_Defaults(t *testing.T) {
 		t.Errorf("CancelSchedulerEnabled = %v, want true", cfg.CancelSchedulerEnabled)
 	}
 
+	if cfg.CancelDryRun != false {
+		t.Errorf("CancelDryRun = %v, want false", cfg.CancelDryRun)
+	}
+
+	if cfg.CancelCron != "" {
+		t.Errorf("CancelCron = %v, want empty", cfg.CancelCron)
+	}
+
+	if len(cfg.CancelBlackout) != 0 {
+		t.Errorf("CancelBlackout = %v, want empty", cfg.CancelBlackout)
+	}
+
+	if cfg.CancelTimezone != "UTC" {
+		t.Errorf("CancelTimezone = %v, want UTC", cfg.CancelTimezone)
+	}
+
+	if cfg.CancelJitterSec != 0 {
+		t.Errorf("CancelJitterSec = %v, want 0", cfg.CancelJitterSec)
+	}
+
+	if cfg.LeaderHeartbeatSec != 5 {
+		t.Errorf("LeaderHeartbeatSec = %v, want 5", cfg.LeaderHeartbeatSec)
+	}
+
+	if cfg.CancelStrategy != "odd_latest" {
+		t.Errorf("CancelStrategy = %v, want odd_latest", cfg.CancelStrategy)
+	}
+
+	if cfg.CancelOlderThanMin != 60 {
+		t.Errorf("CancelOlderThanMin = %v, want 60", cfg.CancelOlderThanMin)
+	}
+
+	if cfg.CancelRetryMaxAttempts != 5 {
+		t.Errorf("CancelRetryMaxAttempts = %v, want 5", cfg.CancelRetryMaxAttempts)
+	}
+
+	if cfg.CancelRetryBaseMin != 5 {
+		t.Errorf("CancelRetryBaseMin = %v, want 5", cfg.CancelRetryBaseMin)
+	}
+
+	if cfg.CancelRetryMaxMin != 1440 {
+		t.Errorf("CancelRetryMaxMin = %v, want 1440", cfg.CancelRetryMaxMin)
+	}
+
+	if cfg.CancelBatchSize != 10 {
+		t.Errorf("CancelBatchSize = %v, want 10", cfg.CancelBatchSize)
+	}
+
+	if cfg.CancelCycleBudgetSec != 60 {
+		t.Errorf("CancelCycleBudgetSec = %v, want 60", cfg.CancelCycleBudgetSec)
+	}
+
+	if cfg.CancelWorkers != 1 {
+		t.Errorf("CancelWorkers = %v, want 1", cfg.CancelWorkers)
+	}
+	if cfg.CancelCycleMaxCandidates != 1000 {
+		t.Errorf("CancelCycleMaxCandidates = %v, want 1000", cfg.CancelCycleMaxCandidates)
+	}
+
+	if cfg.HoldTTLMin != 30 {
+		t.Errorf("HoldTTLMin = %v, want 30", cfg.HoldTTLMin)
+	}
+
+	if cfg.HoldExpiryPeriodMin != 1 {
+		t.Errorf("HoldExpiryPeriodMin = %v, want 1", cfg.HoldExpiryPeriodMin)
+	}
+
+	if cfg.SnapshotPeriodMin != 1440 {
+		t.Errorf("SnapshotPeriodMin = %v, want 1440", cfg.SnapshotPeriodMin)
+	}
+
+	if cfg.SnapshotsEnabled != true {
+		t.Errorf("SnapshotsEnabled = %v, want true", cfg.SnapshotsEnabled)
+	}
+
+	if cfg.ReconcilePeriodMin != 60 {
+		t.Errorf("ReconcilePeriodMin = %v, want 60", cfg.ReconcilePeriodMin)
+	}
+
+	if cfg.ReconcileEnabled != true {
+		t.Errorf("ReconcileEnabled = %v, want true", cfg.ReconcileEnabled)
+	}
+
+	if cfg.ShutdownDelaySec != 0 {
+		t.Errorf("ShutdownDelaySec = %v, want 0", cfg.ShutdownDelaySec)
+	}
+	if cfg.ShutdownTimeoutSec != 25 {
+		t.Errorf("ShutdownTimeoutSec = %v, want 25", cfg.ShutdownTimeoutSec)
+	}
+
+	if cfg.HealthCheckPeriodSec != 10 {
+		t.Errorf("HealthCheckPeriodSec = %v, want 10", cfg.HealthCheckPeriodSec)
+	}
+
+	if cfg.HealthSchedulerMaxMin != 15 {
+		t.Errorf("HealthSchedulerMaxMin = %v, want 15", cfg.HealthSchedulerMaxMin)
+	}
+
 	if cfg.LogLevel != "info" {
 		t.Errorf("LogLevel = %v, want info", cfg.LogLevel)
 	}
@@ -6,7 +6,11 @@ import (
 	"fmt"
 	"time"
 
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"go.opentelemetry.io/otel"
 	"go.uber.org/zap"
 )
 
@@ -15,12 +19,18 @@ type DB struct {
 }
 
 func NewConnection(dsn string) (*DB, error) {
-	db, err := sql.Open("pgx", dsn)
+	config, err := pgx.ParseConfig(dsn)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+		return nil, fmt.Errorf("failed to parse database DSN: %w", err)
 	}
+	config.Tracer = newQueryTracer(otel.GetTracerProvider())
 
-	return &DB{DB: db}, nil
+	return &DB{DB: stdlib.OpenDB(*config)}, nil
+}
+
+// StatsCollector exposes the connection pool stats as go_sql_* metrics.
+func (d *DB) StatsCollector() prometheus.Collector {
+	return collectors.NewDBStatsCollector(d.DB, "balance")
 }
 
 func (d *DB) HealthCheck(ctx context.Context) error {
@@ -32,6 +42,6 @@ func (d *DB) HealthCheck(ctx context.Context) error {
 		return fmt.Errorf("database health check failed: %w", err)
 	}
 
-	zap.L().Info("database health check passed")
+	zap.L().Debug("database health check passed")
 	return nil
 }
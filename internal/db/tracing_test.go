@@ -0,0 +1,58 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func newRecordedTracer() (queryTracer, *sdktrace.TracerProvider, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	return newQueryTracer(provider), provider, recorder
+}
+
+func TestQueryTracer(t *testing.T) {
+	qt, provider, recorder := newRecordedTracer()
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+
+	qctx := qt.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: "\n\tupdate accounts SET balance = 0"})
+	qt.TraceQueryEnd(qctx, nil, pgx.TraceQueryEndData{Err: errors.New("boom")})
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("ended spans = %d, want 2", len(spans))
+	}
+	query := spans[0]
+	if query.Name() != "sql UPDATE" {
+		t.Errorf("span name = %q, want %q", query.Name(), "sql UPDATE")
+	}
+	if query.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Error("query span is not a child of the span in its context")
+	}
+	if query.Status().Code != codes.Error {
+		t.Errorf("span status = %v, want Error", query.Status().Code)
+	}
+}
+
+func TestQueryTracer_WithoutParent(t *testing.T) {
+	qt, _, recorder := newRecordedTracer()
+
+	ctx := context.Background()
+	qctx := qt.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: "SELECT 1"})
+	qt.TraceQueryEnd(qctx, nil, pgx.TraceQueryEndData{})
+
+	if qctx != ctx {
+		t.Error("TraceQueryStart() changed a context without a span")
+	}
+	if spans := recorder.Started(); len(spans) != 0 {
+		t.Errorf("started spans = %d, want none outside of a trace", len(spans))
+	}
+}
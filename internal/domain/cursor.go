@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OperationFilter narrows an account's operation history. Nil fields match anything.
+type OperationFilter struct {
+	AccountID   uuid.UUID
+	Source      *Source
+	State       *State
+	Applied     *bool
+	Canceled    *bool
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+}
+
+// OperationCursor is a keyset position in (created_at DESC, id DESC) order.
+type OperationCursor struct {
+	CreatedAt time.Time
+	ID        int64
+}
+
+func CursorFromOperation(op *Operation) OperationCursor {
+	return OperationCursor{CreatedAt: op.CreatedAt, ID: op.ID}
+}
+
+// Encode returns an opaque page token for the cursor.
+func (c OperationCursor) Encode() string {
+	raw := strconv.FormatInt(c.CreatedAt.UnixMicro(), 10) + ":" + strconv.FormatInt(c.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeOperationCursor(token string) (*OperationCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("decode cursor: %w", ErrInvalidPageToken)
+	}
+
+	ts, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, fmt.Errorf("decode cursor: %w", ErrInvalidPageToken)
+	}
+
+	micros, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parse cursor timestamp: %w", ErrInvalidPageToken)
+	}
+
+	opID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parse cursor id: %w", ErrInvalidPageToken)
+	}
+
+	return &OperationCursor{CreatedAt: time.UnixMicro(micros).UTC(), ID: opID}, nil
+}
+
+// EncodeSchedulerRunCursor returns an opaque page token positioned after the run with id.
+func EncodeSchedulerRunCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+func DecodeSchedulerRunCursor(token string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, fmt.Errorf("decode cursor: %w", ErrInvalidPageToken)
+	}
+
+	id, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("parse cursor id: %w", ErrInvalidPageToken)
+	}
+	return id, nil
+}
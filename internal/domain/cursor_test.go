@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestOperationCursor_RoundTrip(t *testing.T) {
+	cursor := OperationCursor{
+		CreatedAt: time.Date(2025, 3, 14, 15, 9, 26, 535897000, time.UTC),
+		ID:        42,
+	}
+
+	decoded, err := DecodeOperationCursor(cursor.Encode())
+	if err != nil {
+		t.Fatalf("DecodeOperationCursor() error = %v", err)
+	}
+
+	if !decoded.CreatedAt.Equal(cursor.CreatedAt) {
+		t.Errorf("CreatedAt = %v, want %v", decoded.CreatedAt, cursor.CreatedAt)
+	}
+	if decoded.ID != cursor.ID {
+		t.Errorf("ID = %v, want %v", decoded.ID, cursor.ID)
+	}
+}
+
+func TestDecodeOperationCursor_Invalid(t *testing.T) {
+	tokens := []string{
+		"!!!",              // not base64
+		"bm8tc2VwYXJhdG9y", // "no-separator"
+		"eDox",             // "x:1"
+		"MTo",              // "1:"
+	}
+
+	for _, token := range tokens {
+		if _, err := DecodeOperationCursor(token); !errors.Is(err, ErrInvalidPageToken) {
+			t.Errorf("DecodeOperationCursor(%q) error = %v, want %v", token, err, ErrInvalidPageToken)
+		}
+	}
+}
+
+func TestSchedulerRunCursor_RoundTrip(t *testing.T) {
+	id, err := DecodeSchedulerRunCursor(EncodeSchedulerRunCursor(1234))
+	if err != nil {
+		t.Fatalf("DecodeSchedulerRunCursor() error = %v", err)
+	}
+	if id != 1234 {
+		t.Errorf("id = %v, want 1234", id)
+	}
+}
+
+func TestDecodeSchedulerRunCursor_Invalid(t *testing.T) {
+	tokens := []string{
+		"!!!", // not base64
+		"eA",  // "x"
+		"MA",  // "0"
+		"LTE", // "-1"
+	}
+
+	for _, token := range tokens {
+		if _, err := DecodeSchedulerRunCursor(token); !errors.Is(err, ErrInvalidPageToken) {
+			t.Errorf("DecodeSchedulerRunCursor(%q) error = %v, want %v", token, err, ErrInvalidPageToken)
+		}
+	}
+}
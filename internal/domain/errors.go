@@ -1,9 +1,43 @@
 package domain
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
-	ErrNotFound        = errors.New("not found")
-	ErrDuplicateTx     = errors.New("duplicate transaction")
-	ErrNegativeBalance = errors.New("negative balance")
+	ErrNotFound            = errors.New("not found")
+	ErrOperationNotFound   = errors.New("operation not found")
+	ErrDuplicateTx         = errors.New("duplicate transaction")
+	ErrIdempotencyConflict = errors.New("tx_id reused with a different payload")
+	ErrNegativeBalance     = errors.New("negative balance")
+	ErrInvalidPageToken    = errors.New("invalid page token")
+	ErrHoldNotFound        = errors.New("hold not found")
+	ErrHoldClosed          = errors.New("hold is already closed")
+	ErrCaptureExceedsHold  = errors.New("capture amount exceeds hold")
+	ErrWatchInterrupted    = errors.New("balance watch interrupted")
+	ErrBalanceAtInFuture   = errors.New("balance requested at a future time")
+
+	ErrOperationNotCancellable   = errors.New("operation cannot be canceled")
+	ErrInsufficientFundsToCancel = errors.New("insufficient funds to cancel operation")
+
+	ErrNotSchedulerLeader = errors.New("replica is not the scheduler leader")
+	ErrSchedulerPaused    = errors.New("scheduler is paused")
+	ErrSchedulerBusy      = errors.New("cancellation cycle already running")
+	ErrSchedulerDryRun    = errors.New("scheduler is in dry-run mode")
 )
+
+// BatchItemError reports the item that aborted an all-or-nothing batch.
+type BatchItemError struct {
+	Index int
+	TxID  string
+	Err   error
+}
+
+func (e *BatchItemError) Error() string {
+	return fmt.Sprintf("batch item %d (tx_id %s): %v", e.Index, e.TxID, e.Err)
+}
+
+func (e *BatchItemError) Unwrap() error {
+	return e.Err
+}
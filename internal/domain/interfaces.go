@@ -10,14 +10,45 @@ import (
 
 type BalanceRepository interface {
 	GetAccount(ctx context.Context, accountID uuid.UUID) (*Account, error)
+	GetBalanceAt(ctx context.Context, accountID uuid.UUID, at time.Time) (decimal.Decimal, error)
 	CreateAccount(ctx context.Context, accountID uuid.UUID) error
-	ProcessTransaction(ctx context.Context, op *Operation) (*Account, error)
+	ProcessTransaction(ctx context.Context, op *Operation) (*ProcessOutcome, error)
+	ProcessBatchAtomic(ctx context.Context, ops []*Operation) ([]*ProcessOutcome, error)
+	ProcessBatch(ctx context.Context, ops []*Operation) []BatchItemOutcome
+	Transfer(ctx context.Context, t *Transfer) (*TransferOutcome, error)
+	Reserve(ctx context.Context, op *Operation, ttl time.Duration) (*HoldOutcome, error)
+	Capture(ctx context.Context, holdTxID string, op *Operation) (*HoldOutcome, error)
+	Release(ctx context.Context, holdTxID string, op *Operation) (*HoldOutcome, error)
 	GetOperationByTxID(ctx context.Context, txID string) (*Operation, error)
+	ListOperations(ctx context.Context, filter OperationFilter, after *OperationCursor, limit int) ([]*Operation, error)
 }
 
 type BalanceService interface {
 	Process(ctx context.Context, req *ProcessRequest) (*ProcessResponse, error)
+	BatchProcess(ctx context.Context, req *BatchProcessRequest) (*BatchProcessResponse, error)
+	Transfer(ctx context.Context, req *TransferRequest) (*TransferResponse, error)
+	Reserve(ctx context.Context, req *ReserveRequest) (*HoldResponse, error)
+	Capture(ctx context.Context, req *CaptureRequest) (*HoldResponse, error)
+	Release(ctx context.Context, req *ReleaseRequest) (*HoldResponse, error)
 	GetBalance(ctx context.Context, req *GetBalanceRequest) (*GetBalanceResponse, error)
+	GetBalanceAt(ctx context.Context, req *GetBalanceAtRequest) (*GetBalanceAtResponse, error)
+	WatchBalance(ctx context.Context, req *WatchBalanceRequest, send func(*BalanceEvent) error) error
+	GetOperation(ctx context.Context, req *GetOperationRequest) (*GetOperationResponse, error)
+	ListOperations(ctx context.Context, req *ListOperationsRequest) (*ListOperationsResponse, error)
+	CancelOperation(ctx context.Context, req *CancelOperationRequest) (*CancelOperationResponse, error)
+}
+
+// OperationCanceller reverses an operation with a compensating cancel::
+// operation. It reports true if the operation was already canceled.
+type OperationCanceller interface {
+	CancelOperation(ctx context.Context, txID string, reason string) (bool, error)
+}
+
+// BalanceWatcher delivers balance changes committed by any replica.
+// The channel is closed when events may have been lost; the caller
+// must unsubscribe when it stops reading.
+type BalanceWatcher interface {
+	Subscribe(accountID uuid.UUID) (<-chan *BalanceEvent, func())
 }
 
 type ProcessRequest struct {
@@ -33,6 +64,48 @@ type ProcessResponse struct {
 	Status    ProcessStatus
 	Balance   decimal.Decimal
 	Timestamp time.Time
+	Replayed  bool
+}
+
+type BatchMode int
+
+const (
+	// BatchModeAllOrNothing commits every item or none of them
+	BatchModeAllOrNothing BatchMode = iota
+	// BatchModeBestEffort commits each item on its own and reports per-item results
+	BatchModeBestEffort
+)
+
+type BatchProcessRequest struct {
+	Mode  BatchMode
+	Items []*ProcessRequest
+}
+
+// BatchItemResult holds either the response or the error for one item.
+type BatchItemResult struct {
+	Response *ProcessResponse
+	Err      error
+}
+
+type BatchProcessResponse struct {
+	Results []BatchItemResult
+}
+
+type TransferRequest struct {
+	FromAccountID uuid.UUID
+	ToAccountID   uuid.UUID
+	Source        Source
+	Amount        decimal.Decimal
+	TxID          string
+}
+
+type TransferResponse struct {
+	TxID        string
+	Status      ProcessStatus
+	FromBalance decimal.Decimal
+	ToBalance   decimal.Decimal
+	Timestamp   time.Time
+	Replayed    bool
 }
 
 type GetBalanceRequest struct {
@@ -41,9 +114,89 @@ type GetBalanceRequest struct {
 
 type GetBalanceResponse struct {
 	Balance   decimal.Decimal
+	Available decimal.Decimal
 	UpdatedAt time.Time
 }
 
+// GetBalanceAtRequest asks for the balance right before At: operations
+// created at or after At are not counted.
+type GetBalanceAtRequest struct {
+	AccountID uuid.UUID
+	At        time.Time
+}
+
+type GetBalanceAtResponse struct {
+	Balance decimal.Decimal
+	At      time.Time
+}
+
+type WatchBalanceRequest struct {
+	AccountID uuid.UUID
+}
+
+type ReserveRequest struct {
+	AccountID uuid.UUID
+	Source    Source
+	Amount    decimal.Decimal
+	TxID      string
+}
+
+type CaptureRequest struct {
+	HoldTxID string
+	// Amount to settle; zero captures the whole hold
+	Amount decimal.Decimal
+	TxID   string
+}
+
+type ReleaseRequest struct {
+	HoldTxID string
+	TxID     string
+}
+
+type HoldResponse struct {
+	TxID      string
+	HoldTxID  string
+	Status    ProcessStatus
+	Balance   decimal.Decimal
+	Available decimal.Decimal
+	Timestamp time.Time
+	ExpiresAt time.Time
+	Replayed  bool
+}
+
+type GetOperationRequest struct {
+	TxID string
+}
+
+type GetOperationResponse struct {
+	Operation    *Operation
+	Compensation *Operation
+}
+
+type ListOperationsRequest struct {
+	Filter    OperationFilter
+	PageSize  int
+	PageToken string
+}
+
+type ListOperationsResponse struct {
+	Operations    []*Operation
+	NextPageToken string
+}
+
+type CancelOperationRequest struct {
+	TxID   string
+	Reason string
+}
+
+// CancelOperationResponse holds the canceled operation and its compensation.
+// Replayed is set when the operation had been canceled before.
+type CancelOperationResponse struct {
+	Operation    *Operation
+	Compensation *Operation
+	Replayed     bool
+}
+
 type ProcessStatus int
 
 const (
@@ -51,3 +204,16 @@ const (
 	StatusAlreadyProcessed
 	StatusRejectedNegative
 )
+
+func (s ProcessStatus) String() string {
+	switch s {
+	case StatusOK:
+		return "ok"
+	case StatusAlreadyProcessed:
+		return "already_processed"
+	case StatusRejectedNegative:
+		return "rejected_negative"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// systemAccounts are the counter-accounts money comes from and goes to, one
+// per source. They are seeded by the 007_ledger migration.
+var systemAccounts = map[Source]uuid.UUID{
+	SourceGame:    uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+	SourcePayment: uuid.MustParse("00000000-0000-0000-0000-000000000002"),
+	SourceService: uuid.MustParse("00000000-0000-0000-0000-000000000003"),
+}
+
+func SystemAccountID(source Source) (uuid.UUID, bool) {
+	id, ok := systemAccounts[source]
+	return id, ok
+}
+
+// IsSystemAccount reports whether id is reserved for a system counter-account.
+func IsSystemAccount(id uuid.UUID) bool {
+	for _, systemID := range systemAccounts {
+		if id == systemID {
+			return true
+		}
+	}
+	return false
+}
+
+// LedgerEntry is one side of a double-entry posting. Entries of a tx_id sum to zero.
+type LedgerEntry struct {
+	TxID      string
+	AccountID uuid.UUID
+	Amount    decimal.Decimal
+}
+
+// NewLedgerEntries posts delta on accountID against the system account of source.
+// A zero delta, as for holds and releases, posts nothing.
+func NewLedgerEntries(txID string, accountID uuid.UUID, source Source, delta decimal.Decimal) ([]LedgerEntry, error) {
+	systemID, ok := SystemAccountID(source)
+	if !ok {
+		return nil, fmt.Errorf("no system account for source %q", source)
+	}
+	if delta.IsZero() {
+		return nil, nil
+	}
+
+	return []LedgerEntry{
+		{TxID: txID, AccountID: accountID, Amount: delta},
+		{TxID: txID, AccountID: systemID, Amount: delta.Neg()},
+	}, nil
+}
@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestNewLedgerEntries(t *testing.T) {
+	accountID := uuid.New()
+	gameID, _ := SystemAccountID(SourceGame)
+
+	entries, err := NewLedgerEntries("tx-1", accountID, SourceGame, decimal.NewFromFloat(10.50))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+
+	if entries[0].AccountID != accountID || !entries[0].Amount.Equal(decimal.NewFromFloat(10.50)) {
+		t.Errorf("user entry = %+v", entries[0])
+	}
+	if entries[1].AccountID != gameID || !entries[1].Amount.Equal(decimal.NewFromFloat(-10.50)) {
+		t.Errorf("system entry = %+v", entries[1])
+	}
+
+	sum := decimal.Zero
+	for _, e := range entries {
+		if e.TxID != "tx-1" {
+			t.Errorf("entry tx_id = %v, want tx-1", e.TxID)
+		}
+		sum = sum.Add(e.Amount)
+	}
+	if !sum.IsZero() {
+		t.Errorf("entries sum to %v, want 0", sum)
+	}
+
+	if entries, _ := NewLedgerEntries("tx-2", accountID, SourceGame, decimal.Zero); len(entries) != 0 {
+		t.Errorf("zero delta posted %d entries, want 0", len(entries))
+	}
+
+	if _, err := NewLedgerEntries("tx-3", accountID, Source("unknown"), decimal.NewFromFloat(1)); err == nil {
+		t.Error("expected error for unknown source")
+	}
+}
+
+func TestIsSystemAccount(t *testing.T) {
+	for _, source := range []Source{SourceGame, SourcePayment, SourceService} {
+		id, ok := SystemAccountID(source)
+		if !ok {
+			t.Fatalf("no system account for %v", source)
+		}
+		if !IsSystemAccount(id) {
+			t.Errorf("IsSystemAccount(%v) = false, want true", id)
+		}
+	}
+
+	if IsSystemAccount(uuid.New()) {
+		t.Error("IsSystemAccount(random) = true, want false")
+	}
+}
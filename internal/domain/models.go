@@ -1,6 +1,9 @@
 package domain
 
 import (
+	"bytes"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -20,14 +23,73 @@ type State string
 const (
 	StateDeposit  State = "deposit"
 	StateWithdraw State = "withdraw"
+
+	// hold lifecycle: a hold reserves funds, a capture settles part or all of
+	// them and releases the rest, a release returns them without moving money
+	StateHold    State = "hold"
+	StateCapture State = "capture"
+	StateRelease State = "release"
 )
 
+// ExpiredHoldTxIDPrefix marks release operations written for expired holds.
+const ExpiredHoldTxIDPrefix = "expire::"
+
+func ExpiredHoldTxID(holdTxID string) string {
+	return ExpiredHoldTxIDPrefix + holdTxID
+}
+
+// CompensatingTxIDPrefix marks operations written to reverse another operation.
+const CompensatingTxIDPrefix = "cancel::"
+
+func CompensatingTxID(txID string) string {
+	return CompensatingTxIDPrefix + txID
+}
+
 type Account struct {
 	ID        uuid.UUID
 	Balance   decimal.Decimal
+	Held      decimal.Decimal
+	UpdatedAt time.Time
+}
+
+// Available is the part of the balance not reserved by open holds.
+func (a *Account) Available() decimal.Decimal {
+	return a.Balance.Sub(a.Held)
+}
+
+// BalanceEvent is the state of an account right after TxID changed it.
+// The first event of a watch has no TxID.
+type BalanceEvent struct {
+	AccountID uuid.UUID
+	TxID      string
+	Balance   decimal.Decimal
+	Available decimal.Decimal
 	UpdatedAt time.Time
 }
 
+// NewBalanceEvent describes acc as changed by txID.
+func NewBalanceEvent(acc *Account, txID string) *BalanceEvent {
+	return &BalanceEvent{
+		AccountID: acc.ID,
+		TxID:      txID,
+		Balance:   acc.Balance,
+		Available: acc.Available(),
+		UpdatedAt: acc.UpdatedAt,
+	}
+}
+
+// Hold reserves Amount on an account until it is captured, released or expires.
+type Hold struct {
+	TxID      string
+	AccountID uuid.UUID
+	Source    Source
+	Amount    decimal.Decimal
+	Captured  decimal.Decimal
+	ExpiresAt time.Time
+	ClosedAt  *time.Time
+	ClosedBy  *string
+}
+
 type Operation struct {
 	ID         int64
 	TxID       string
@@ -39,4 +101,117 @@ type Operation struct {
 	Applied    bool
 	CanceledAt *time.Time
 	CancelNote *string
+	TransferID *string
+}
+
+// Transfer moves Amount from one account to another. It is stored as two
+// operations, a withdraw leg and a deposit leg, linked by TransferID.
+type Transfer struct {
+	TxID          string
+	FromAccountID uuid.UUID
+	ToAccountID   uuid.UUID
+	Source        Source
+	Amount        decimal.Decimal
+}
+
+// Transfer legs are stored under the transfer's tx_id with these suffixes.
+const (
+	TransferDebitTxIDSuffix  = "::debit"
+	TransferCreditTxIDSuffix = "::credit"
+)
+
+func TransferDebitTxID(txID string) string {
+	return txID + TransferDebitTxIDSuffix
+}
+
+func TransferCreditTxID(txID string) string {
+	return txID + TransferCreditTxIDSuffix
+}
+
+// IsReservedTxID reports whether txID has one of the forms the service gives
+// the operations it writes itself; clients cannot use them.
+func IsReservedTxID(txID string) bool {
+	return strings.HasPrefix(txID, CompensatingTxIDPrefix) ||
+		strings.HasPrefix(txID, ExpiredHoldTxIDPrefix) ||
+		strings.HasSuffix(txID, TransferDebitTxIDSuffix) ||
+		strings.HasSuffix(txID, TransferCreditTxIDSuffix)
+}
+
+// Legs returns the withdraw and deposit operations making up the transfer.
+func (t *Transfer) Legs() (debit, credit *Operation) {
+	debit = &Operation{
+		TxID:       TransferDebitTxID(t.TxID),
+		AccountID:  t.FromAccountID,
+		Source:     t.Source,
+		State:      StateWithdraw,
+		Amount:     t.Amount,
+		TransferID: &t.TxID,
+	}
+	credit = &Operation{
+		TxID:       TransferCreditTxID(t.TxID),
+		AccountID:  t.ToAccountID,
+		Source:     t.Source,
+		State:      StateDeposit,
+		Amount:     t.Amount,
+		TransferID: &t.TxID,
+	}
+	return debit, credit
+}
+
+// ProcessOutcome is the result of processing a tx_id. It is stored with the
+// operation so that retries get the original answer back.
+type ProcessOutcome struct {
+	Status      ProcessStatus
+	Balance     decimal.Decimal
+	Available   decimal.Decimal
+	ProcessedAt time.Time
+	Replayed    bool
+}
+
+// HoldOutcome is the result of a hold operation. Hold is nil when a reserve was rejected.
+type HoldOutcome struct {
+	ProcessOutcome
+	Hold *Hold
+}
+
+// AccountLockOrder returns the distinct account ids in the order rows must be
+// locked in, so that concurrent transactions over the same accounts cannot deadlock.
+func AccountLockOrder(ids ...uuid.UUID) []uuid.UUID {
+	sorted := slices.Clone(ids)
+	slices.SortFunc(sorted, func(a, b uuid.UUID) int {
+		return bytes.Compare(a[:], b[:])
+	})
+	return slices.Compact(sorted)
+}
+
+// TransferOutcome is the stored result of a transfer, returned again on replays.
+type TransferOutcome struct {
+	Status      ProcessStatus
+	FromBalance decimal.Decimal
+	ToBalance   decimal.Decimal
+	ProcessedAt time.Time
+	Replayed    bool
+}
+
+// BatchItemOutcome is the result of one best-effort batch item: an outcome or an error.
+type BatchItemOutcome struct {
+	Outcome *ProcessOutcome
+	Err     error
+}
+
+// SamePayload reports whether other describes the same money movement as op,
+// ignoring storage fields like ID, timestamps and cancellation state.
+func (op *Operation) SamePayload(other *Operation) bool {
+	return op.AccountID == other.AccountID &&
+		op.Source == other.Source &&
+		op.State == other.State &&
+		op.Amount.Equal(other.Amount) &&
+		sameTransfer(op.TransferID, other.TransferID)
+}
+
+func sameTransfer(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
 }
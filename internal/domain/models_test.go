@@ -2,6 +2,9 @@ package domain
 
 import (
 	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 )
 
 func TestSource_Constants(t *testing.T) {
@@ -28,6 +31,9 @@ func TestState_Constants(t *testing.T) {
 	}{
 		{StateDeposit, "deposit"},
 		{StateWithdraw, "withdraw"},
+		{StateHold, "hold"},
+		{StateCapture, "capture"},
+		{StateRelease, "release"},
 	}
 
 	for _, tt := range tests {
@@ -48,3 +54,74 @@ func TestProcessStatus_Constants(t *testing.T) {
 		t.Errorf("StatusRejectedNegative = %v, want 2", StatusRejectedNegative)
 	}
 }
+
+func TestOperation_SamePayload(t *testing.T) {
+	base := Operation{
+		AccountID: uuid.New(),
+		Source:    SourcePayment,
+		State:     StateDeposit,
+		Amount:    decimal.RequireFromString("10.50"),
+	}
+
+	sameAmount := base
+	sameAmount.Amount = decimal.RequireFromString("10.5")
+	otherAccount := base
+	otherAccount.AccountID = uuid.New()
+	otherSource := base
+	otherSource.Source = SourceGame
+	otherState := base
+	otherState.State = StateWithdraw
+	otherAmount := base
+	otherAmount.Amount = decimal.RequireFromString("10.51")
+
+	tests := []struct {
+		name     string
+		other    Operation
+		expected bool
+	}{
+		{"same amount, different scale", sameAmount, true},
+		{"different account", otherAccount, false},
+		{"different source", otherSource, false},
+		{"different state", otherState, false},
+		{"different amount", otherAmount, false},
+	}
+
+	for _, tt := range tests {
+		if got := base.SamePayload(&tt.other); got != tt.expected {
+			t.Errorf("%s: SamePayload() = %v, want %v", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestAccountLockOrder(t *testing.T) {
+	a := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	b := uuid.MustParse("00000000-0000-0000-0000-000000000002")
+
+	for _, ids := range [][]uuid.UUID{{a, b}, {b, a}, {b, a, b}} {
+		got := AccountLockOrder(ids...)
+		if len(got) != 2 || got[0] != a || got[1] != b {
+			t.Errorf("AccountLockOrder(%v) = %v, want [%v %v]", ids, got, a, b)
+		}
+	}
+}
+
+func TestIsReservedTxID(t *testing.T) {
+	tests := []struct {
+		txID     string
+		expected bool
+	}{
+		{"tx-1", false},
+		{"tx::1", false},
+		{"debit", false},
+		{CompensatingTxID("tx-1"), true},
+		{ExpiredHoldTxID("hold-1"), true},
+		{TransferDebitTxID("tx-1"), true},
+		{TransferCreditTxID("tx-1"), true},
+	}
+
+	for _, tt := range tests {
+		if got := IsReservedTxID(tt.txID); got != tt.expected {
+			t.Errorf("IsReservedTxID(%q) = %v, want %v", tt.txID, got, tt.expected)
+		}
+	}
+}
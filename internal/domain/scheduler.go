@@ -0,0 +1,110 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// SchedulerTrigger tells what started a cancellation cycle.
+type SchedulerTrigger string
+
+const (
+	SchedulerTriggerSchedule SchedulerTrigger = "schedule"
+	SchedulerTriggerManual   SchedulerTrigger = "manual"
+)
+
+type SchedulerRunStatus string
+
+const (
+	SchedulerRunRunning     SchedulerRunStatus = "running"
+	SchedulerRunCompleted   SchedulerRunStatus = "completed"
+	SchedulerRunInterrupted SchedulerRunStatus = "interrupted"
+	// SchedulerRunAbandoned is a run whose replica died before finishing it
+	SchedulerRunAbandoned SchedulerRunStatus = "abandoned"
+)
+
+// CancelOutcome is what a cancellation cycle did with one candidate.
+type CancelOutcome string
+
+const (
+	CancelOutcomeCancelled     CancelOutcome = "cancelled"
+	CancelOutcomeSkipped       CancelOutcome = "skipped"
+	CancelOutcomeFailed        CancelOutcome = "failed"
+	CancelOutcomeNotApplicable CancelOutcome = "not_applicable"
+)
+
+type SchedulerRunOutcome struct {
+	OperationID int64
+	TxID        string
+	Result      CancelOutcome
+	// Parked is set when this attempt used up the operation's retries
+	Parked      bool
+	AttemptedAt time.Time
+}
+
+// SchedulerRun is one cancellation cycle. Outcomes are only loaded on request.
+type SchedulerRun struct {
+	ID            int64
+	StartedAt     time.Time
+	FinishedAt    *time.Time
+	Holder        string
+	Trigger       SchedulerTrigger
+	Status        SchedulerRunStatus
+	Candidates    int
+	Cancelled     int
+	Skipped       int
+	Failed        int
+	NotApplicable int
+	Parked        int
+	Outcomes      []SchedulerRunOutcome
+}
+
+// SchedulerStatus is the scheduler as seen by the replica that answers.
+type SchedulerStatus struct {
+	Holder      string
+	IsLeader    bool
+	Enabled     bool
+	Running     bool
+	DryRun      bool
+	Paused      bool
+	PausedAt    *time.Time
+	PauseReason string
+	Strategy    string
+	Schedule    string
+	NextRunAt   *time.Time
+	LastRun     *SchedulerRun
+}
+
+// SchedulerController runs and inspects the cancellation scheduler.
+type SchedulerController interface {
+	Status(ctx context.Context) (*SchedulerStatus, error)
+	// ListRuns returns runs older than beforeID (all when zero), newest first.
+	ListRuns(ctx context.Context, beforeID int64, limit int, withOutcomes bool) ([]*SchedulerRun, error)
+	Pause(ctx context.Context, reason string) error
+	Resume(ctx context.Context) error
+	// RunNow runs one cycle on this replica and returns its record.
+	RunNow(ctx context.Context) (*SchedulerRun, error)
+}
+
+type SchedulerAdminService interface {
+	GetSchedulerStatus(ctx context.Context) (*SchedulerStatus, error)
+	ListSchedulerRuns(ctx context.Context, req *ListSchedulerRunsRequest) (*ListSchedulerRunsResponse, error)
+	PauseScheduler(ctx context.Context, req *PauseSchedulerRequest) (*SchedulerStatus, error)
+	ResumeScheduler(ctx context.Context) (*SchedulerStatus, error)
+	TriggerRunNow(ctx context.Context) (*SchedulerRun, error)
+}
+
+type ListSchedulerRunsRequest struct {
+	PageSize        int
+	PageToken       string
+	IncludeOutcomes bool
+}
+
+type ListSchedulerRunsResponse struct {
+	Runs          []*SchedulerRun
+	NextPageToken string
+}
+
+type PauseSchedulerRequest struct {
+	Reason string
+}
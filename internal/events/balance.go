@@ -0,0 +1,54 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/domain"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// BalanceChannel is the NOTIFY channel carrying balance changes.
+const BalanceChannel = "balance_changed"
+
+const sqlNotify = `SELECT pg_notify($1, $2)`
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+type balancePayload struct {
+	AccountID uuid.UUID       `json:"account_id"`
+	TxID      string          `json:"tx_id"`
+	Balance   decimal.Decimal `json:"balance"`
+	Available decimal.Decimal `json:"available"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// PublishBalance queues ev on the notification channel. Postgres delivers it
+// only if the surrounding transaction (or savepoint) commits.
+func PublishBalance(ctx context.Context, tx execer, ev *domain.BalanceEvent) error {
+	payload, err := json.Marshal(balancePayload(*ev))
+	if err != nil {
+		return fmt.Errorf("encode balance event: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, sqlNotify, BalanceChannel, string(payload)); err != nil {
+		return fmt.Errorf("notify balance event: %w", err)
+	}
+	return nil
+}
+
+func decodeBalance(payload string) (*domain.BalanceEvent, error) {
+	var p balancePayload
+	if err := json.Unmarshal([]byte(payload), &p); err != nil {
+		return nil, fmt.Errorf("decode balance event: %w", err)
+	}
+
+	ev := domain.BalanceEvent(p)
+	return &ev, nil
+}
@@ -0,0 +1,189 @@
+package events
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/db"
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/stdlib"
+	"go.uber.org/zap"
+)
+
+const (
+	// subscriberBuffer is how many events a subscriber may fall behind before it is dropped
+	subscriberBuffer = 64
+	reconnectDelay   = time.Second
+)
+
+type subscription struct {
+	accountID uuid.UUID
+	events    chan *domain.BalanceEvent
+}
+
+// Broker listens on BalanceChannel with a dedicated connection and fans
+// the events out to in-process subscribers, so changes committed by any
+// replica reach every watcher.
+type Broker struct {
+	db  *db.DB
+	log *zap.Logger
+
+	mu sync.Mutex
+	// listening is true while LISTEN is active on the dedicated connection
+	listening bool
+	subs      map[uuid.UUID]map[*subscription]struct{}
+}
+
+func NewBroker(database *db.DB, log *zap.Logger) *Broker {
+	return &Broker{
+		db:   database,
+		log:  log.Named("balance-events"),
+		subs: make(map[uuid.UUID]map[*subscription]struct{}),
+	}
+}
+
+// Subscribe registers a subscriber for accountID. The returned channel is
+// closed if the subscriber lags behind or the listener loses its connection.
+// While the listener is not connected the channel is returned already
+// closed, since events committed meanwhile would be missed.
+func (b *Broker) Subscribe(accountID uuid.UUID) (<-chan *domain.BalanceEvent, func()) {
+	sub := &subscription{
+		accountID: accountID,
+		events:    make(chan *domain.BalanceEvent, subscriberBuffer),
+	}
+
+	b.mu.Lock()
+	if !b.listening {
+		b.mu.Unlock()
+		close(sub.events)
+		return sub.events, func() {}
+	}
+	if b.subs[accountID] == nil {
+		b.subs[accountID] = make(map[*subscription]struct{})
+	}
+	b.subs[accountID][sub] = struct{}{}
+	b.mu.Unlock()
+
+	return sub.events, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.remove(sub)
+	}
+}
+
+func (b *Broker) Run(ctx context.Context) {
+	b.log.Info("starting balance event listener", zap.String("channel", BalanceChannel))
+
+	for {
+		err := b.listen(ctx)
+
+		// notifications sent while we were not listening are lost
+		b.dropAll()
+
+		if ctx.Err() != nil {
+			b.log.Info("balance event listener stopped")
+			return
+		}
+		b.log.Warn("balance event listener failed, reconnecting", zap.Error(err))
+
+		select {
+		case <-ctx.Done():
+			b.log.Info("balance event listener stopped")
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+func (b *Broker) listen(ctx context.Context) error {
+	conn, err := b.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		stdConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("unexpected driver connection %T", driverConn)
+		}
+		pgxConn := stdConn.Conn()
+
+		if _, err := pgxConn.Exec(ctx, "LISTEN "+BalanceChannel); err != nil {
+			return fmt.Errorf("%w: listen: %v", driver.ErrBadConn, err)
+		}
+		b.setListening()
+
+		for {
+			n, err := pgxConn.WaitForNotification(ctx)
+			if err != nil {
+				// the connection is still subscribed: never hand it back to the pool
+				return fmt.Errorf("%w: wait for notification: %v", driver.ErrBadConn, err)
+			}
+
+			ev, err := decodeBalance(n.Payload)
+			if err != nil {
+				b.log.Warn("skipping malformed balance event", zap.String("payload", n.Payload), zap.Error(err))
+				continue
+			}
+			b.dispatch(ev)
+		}
+	})
+}
+
+func (b *Broker) dispatch(ev *domain.BalanceEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subs[ev.AccountID] {
+		select {
+		case sub.events <- ev:
+		default:
+			b.log.Warn("dropping lagging balance subscriber", zap.String("account_id", ev.AccountID.String()))
+			b.remove(sub)
+		}
+	}
+}
+
+func (b *Broker) setListening() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.listening = true
+}
+
+// dropAll closes every subscriber and refuses new ones until LISTEN is
+// active again.
+func (b *Broker) dropAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.listening = false
+
+	for _, subs := range b.subs {
+		for sub := range subs {
+			b.remove(sub)
+		}
+	}
+}
+
+// remove closes sub once; b.mu must be held.
+func (b *Broker) remove(sub *subscription) {
+	subs, ok := b.subs[sub.accountID]
+	if !ok {
+		return
+	}
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(b.subs, sub.accountID)
+	}
+	close(sub.events)
+}
@@ -0,0 +1,129 @@
+package events
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/domain"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+func TestBrokerDispatch(t *testing.T) {
+	b := NewBroker(nil, zap.NewNop())
+	b.setListening()
+	accountID := uuid.New()
+
+	events, unsubscribe := b.Subscribe(accountID)
+	other, unsubscribeOther := b.Subscribe(uuid.New())
+	defer unsubscribeOther()
+
+	b.dispatch(&domain.BalanceEvent{AccountID: accountID, TxID: "tx-1"})
+
+	select {
+	case ev := <-events:
+		if ev.TxID != "tx-1" {
+			t.Fatalf("got tx_id %q, want tx-1", ev.TxID)
+		}
+	default:
+		t.Fatal("event was not delivered")
+	}
+	if len(other) != 0 {
+		t.Fatal("event delivered to another account")
+	}
+
+	unsubscribe()
+	unsubscribe() // must be safe to call twice
+	if _, ok := <-events; ok {
+		t.Fatal("channel not closed after unsubscribe")
+	}
+}
+
+func TestBrokerDropsLaggingSubscriber(t *testing.T) {
+	b := NewBroker(nil, zap.NewNop())
+	b.setListening()
+	accountID := uuid.New()
+
+	events, unsubscribe := b.Subscribe(accountID)
+	defer unsubscribe()
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		b.dispatch(&domain.BalanceEvent{AccountID: accountID})
+	}
+
+	received := 0
+	for range events {
+		received++
+	}
+	if received != subscriberBuffer {
+		t.Fatalf("received %d events before close, want %d", received, subscriberBuffer)
+	}
+}
+
+func TestBrokerSubscribeWhileNotListening(t *testing.T) {
+	b := NewBroker(nil, zap.NewNop())
+	accountID := uuid.New()
+
+	// before the first LISTEN succeeds
+	events, unsubscribe := b.Subscribe(accountID)
+	if _, ok := <-events; ok {
+		t.Fatal("channel open before the listener connected")
+	}
+	unsubscribe()
+
+	b.setListening()
+	live, unsubscribeLive := b.Subscribe(accountID)
+	defer unsubscribeLive()
+
+	// connection lost: Run waits before reconnecting
+	b.dropAll()
+	if _, ok := <-live; ok {
+		t.Fatal("channel not closed when the listener lost its connection")
+	}
+
+	events, unsubscribe = b.Subscribe(accountID)
+	defer unsubscribe()
+	b.dispatch(&domain.BalanceEvent{AccountID: accountID, TxID: "tx-1"})
+	if _, ok := <-events; ok {
+		t.Fatal("channel open while the listener is reconnecting")
+	}
+
+	b.setListening()
+	events, unsubscribe = b.Subscribe(accountID)
+	defer unsubscribe()
+	b.dispatch(&domain.BalanceEvent{AccountID: accountID, TxID: "tx-2"})
+	if ev := <-events; ev == nil || ev.TxID != "tx-2" {
+		t.Fatal("event not delivered after reconnect")
+	}
+}
+
+func TestDecodeBalance(t *testing.T) {
+	want := &domain.BalanceEvent{
+		AccountID: uuid.New(),
+		TxID:      "tx-1",
+		Balance:   decimal.RequireFromString("100.50"),
+		Available: decimal.RequireFromString("70.25"),
+		UpdatedAt: time.Date(2025, 1, 2, 3, 4, 5, 6000, time.UTC),
+	}
+
+	payload, err := json.Marshal(balancePayload(*want))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := decodeBalance(string(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.AccountID != want.AccountID || got.TxID != want.TxID ||
+		!got.Balance.Equal(want.Balance) || !got.Available.Equal(want.Available) ||
+		!got.UpdatedAt.Equal(want.UpdatedAt) {
+		t.Fatalf("decodeBalance() = %+v, want %+v", got, want)
+	}
+
+	if _, err := decodeBalance("not json"); err == nil {
+		t.Fatal("expected error for malformed payload")
+	}
+}
@@ -0,0 +1,167 @@
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// SchedulerService is the health service name of the cancel scheduler.
+const SchedulerService = "cancel-scheduler"
+
+// ErrShuttingDown is the readiness error once Shutdown was called.
+var ErrShuttingDown = errors.New("shutting down")
+
+// Database is the part of *db.DB the manager checks.
+type Database interface {
+	HealthCheck(ctx context.Context) error
+}
+
+// Scheduler is the part of *scheduler.Scheduler the manager checks.
+type Scheduler interface {
+	Liveness(now time.Time, maxAge time.Duration) error
+}
+
+// Config lists what the manager reports on. Services are the gRPC services
+// that need the database; the overall "" status follows them too.
+type Config struct {
+	Period          time.Duration
+	SchedulerMaxAge time.Duration
+	Services        []string
+}
+
+// Manager checks the database and the cancel scheduler every Period and
+// reports the results through the gRPC health service and Live and Ready.
+// A database outage makes the replica not ready; a stalled scheduler makes
+// it not live, so an orchestrator restarts it.
+type Manager struct {
+	server    *health.Server
+	db        Database
+	scheduler Scheduler
+	cfg       Config
+	log       *zap.Logger
+
+	mu           sync.Mutex
+	dbErr        error
+	schedulerErr error
+	shutdown     bool
+}
+
+// NewManager reports to server; scheduler is nil when the background
+// scheduler is disabled, and then SchedulerService is not reported.
+func NewManager(server *health.Server, database Database, scheduler Scheduler, cfg Config, log *zap.Logger) *Manager {
+	return &Manager{
+		server:    server,
+		db:        database,
+		scheduler: scheduler,
+		cfg:       cfg,
+		log:       log.Named("health"),
+	}
+}
+
+func (m *Manager) Run(ctx context.Context) {
+	m.log.Info("starting health checks", zap.Duration("period", m.cfg.Period))
+
+	ticker := time.NewTicker(m.cfg.Period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			m.log.Info("health checks stopped")
+			return
+		case <-ticker.C:
+			m.Check(ctx)
+		}
+	}
+}
+
+// Check runs the checks once and updates the reported statuses.
+func (m *Manager) Check(ctx context.Context) {
+	dbErr := m.db.HealthCheck(ctx)
+
+	var schedulerErr error
+	if m.scheduler != nil {
+		schedulerErr = m.scheduler.Liveness(time.Now(), m.cfg.SchedulerMaxAge)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Shutdown has already reported NOT_SERVING for good
+	if m.shutdown {
+		return
+	}
+
+	if changed(m.dbErr, dbErr) {
+		if dbErr != nil {
+			m.log.Error("database unavailable, not serving", zap.Error(dbErr))
+		} else {
+			m.log.Info("database available, serving")
+		}
+	}
+	m.dbErr = dbErr
+	status := servingStatus(dbErr)
+	m.server.SetServingStatus("", status)
+	for _, service := range m.cfg.Services {
+		m.server.SetServingStatus(service, status)
+	}
+
+	if m.scheduler == nil {
+		return
+	}
+	if changed(m.schedulerErr, schedulerErr) {
+		if schedulerErr != nil {
+			m.log.Error("cancel scheduler unhealthy", zap.Error(schedulerErr))
+		} else {
+			m.log.Info("cancel scheduler healthy")
+		}
+	}
+	m.schedulerErr = schedulerErr
+	m.server.SetServingStatus(SchedulerService, servingStatus(schedulerErr))
+}
+
+// Shutdown reports every service NOT_SERVING; later checks do not change it.
+func (m *Manager) Shutdown() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.shutdown = true
+	m.server.Shutdown()
+}
+
+// Live is the liveness probe: it fails while the scheduler is stalled.
+func (m *Manager) Live() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.schedulerErr
+}
+
+// Ready is the readiness probe: it fails while the database is unreachable
+// and once shutdown has started.
+func (m *Manager) Ready() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.shutdown {
+		return ErrShuttingDown
+	}
+	return m.dbErr
+}
+
+func servingStatus(err error) grpc_health_v1.HealthCheckResponse_ServingStatus {
+	if err != nil {
+		return grpc_health_v1.HealthCheckResponse_NOT_SERVING
+	}
+	return grpc_health_v1.HealthCheckResponse_SERVING
+}
+
+// changed reports whether a check went from passing to failing or back.
+func changed(before, after error) bool {
+	return (before == nil) != (after == nil)
+}
@@ -0,0 +1,98 @@
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+type fakeDatabase struct{ err error }
+
+func (d *fakeDatabase) HealthCheck(context.Context) error { return d.err }
+
+type fakeScheduler struct{ err error }
+
+func (s *fakeScheduler) Liveness(time.Time, time.Duration) error { return s.err }
+
+func assertStatus(t *testing.T, server *health.Server, service string, expected grpc_health_v1.HealthCheckResponse_ServingStatus) {
+	t.Helper()
+	resp, err := server.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: service})
+	if err != nil {
+		t.Fatalf("Check(%q) error = %v", service, err)
+	}
+	if resp.Status != expected {
+		t.Errorf("Check(%q) = %v, want %v", service, resp.Status, expected)
+	}
+}
+
+func TestManager_Check(t *testing.T) {
+	database := &fakeDatabase{}
+	scheduler := &fakeScheduler{}
+	server := health.NewServer()
+	m := NewManager(server, database, scheduler, Config{Services: []string{"balance.BalanceService"}}, zap.NewNop())
+
+	m.Check(context.Background())
+	assertStatus(t, server, "", grpc_health_v1.HealthCheckResponse_SERVING)
+	assertStatus(t, server, "balance.BalanceService", grpc_health_v1.HealthCheckResponse_SERVING)
+	assertStatus(t, server, SchedulerService, grpc_health_v1.HealthCheckResponse_SERVING)
+	if err := m.Ready(); err != nil {
+		t.Errorf("Ready() = %v, want nil", err)
+	}
+	if err := m.Live(); err != nil {
+		t.Errorf("Live() = %v, want nil", err)
+	}
+
+	// a database outage takes the replica out of rotation without restarting it
+	database.err = errors.New("connection refused")
+	m.Check(context.Background())
+	assertStatus(t, server, "", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	assertStatus(t, server, "balance.BalanceService", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	assertStatus(t, server, SchedulerService, grpc_health_v1.HealthCheckResponse_SERVING)
+	if err := m.Ready(); err == nil {
+		t.Error("Ready() = nil with the database down, want an error")
+	}
+	if err := m.Live(); err != nil {
+		t.Errorf("Live() = %v with the database down, want nil", err)
+	}
+
+	database.err = nil
+	scheduler.err = errors.New("stalled")
+	m.Check(context.Background())
+	assertStatus(t, server, "balance.BalanceService", grpc_health_v1.HealthCheckResponse_SERVING)
+	assertStatus(t, server, SchedulerService, grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	if err := m.Live(); err == nil {
+		t.Error("Live() = nil with the scheduler stalled, want an error")
+	}
+}
+
+func TestManager_WithoutScheduler(t *testing.T) {
+	server := health.NewServer()
+	m := NewManager(server, &fakeDatabase{}, nil, Config{}, zap.NewNop())
+
+	m.Check(context.Background())
+	_, err := server.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: SchedulerService})
+	if err == nil {
+		t.Errorf("Check(%q) error = nil, want NotFound while the scheduler is disabled", SchedulerService)
+	}
+}
+
+func TestManager_Shutdown(t *testing.T) {
+	server := health.NewServer()
+	m := NewManager(server, &fakeDatabase{}, nil, Config{Services: []string{"balance.BalanceService"}}, zap.NewNop())
+	m.Check(context.Background())
+
+	m.Shutdown()
+	// a check after shutdown does not bring the services back
+	m.Check(context.Background())
+
+	assertStatus(t, server, "", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	assertStatus(t, server, "balance.BalanceService", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	if err := m.Ready(); !errors.Is(err, ErrShuttingDown) {
+		t.Errorf("Ready() = %v, want %v", err, ErrShuttingDown)
+	}
+}
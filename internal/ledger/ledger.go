@@ -0,0 +1,54 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/domain"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const sqlInsertEntry = `
+INSERT INTO ledger_entries (tx_id, account_id, amount)
+VALUES ($1, $2, $3::numeric)
+`
+
+// ErrUnbalanced means the entries of a posting do not sum to zero.
+var ErrUnbalanced = errors.New("ledger entries do not balance")
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Post writes the balanced entries for delta on accountID against the system
+// account of source. The database rejects the commit if the entries of a
+// tx_id do not sum to zero.
+func Post(ctx context.Context, tx execer, txID string, accountID uuid.UUID, source domain.Source, delta decimal.Decimal) error {
+	entries, err := domain.NewLedgerEntries(txID, accountID, source, delta)
+	if err != nil {
+		return fmt.Errorf("post ledger entries: %w", err)
+	}
+	return postEntries(ctx, tx, entries)
+}
+
+// postEntries inserts entries once they are known to balance, so an
+// unbalanced posting fails here rather than at commit.
+func postEntries(ctx context.Context, tx execer, entries []domain.LedgerEntry) error {
+	sum := decimal.Zero
+	for _, e := range entries {
+		sum = sum.Add(e.Amount)
+	}
+	if !sum.IsZero() {
+		return fmt.Errorf("post ledger entries: %w (sum %s)", ErrUnbalanced, sum)
+	}
+
+	for _, e := range entries {
+		if _, err := tx.ExecContext(ctx, sqlInsertEntry, e.TxID, e.AccountID, e.Amount.String()); err != nil {
+			return fmt.Errorf("insert ledger entry: %w", err)
+		}
+	}
+	return nil
+}
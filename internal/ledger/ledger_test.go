@@ -0,0 +1,113 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/domain"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// recordingExecer records the ledger entries it is asked to insert.
+type recordingExecer struct {
+	entries []domain.LedgerEntry
+	err     error
+}
+
+func (r *recordingExecer) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	r.entries = append(r.entries, domain.LedgerEntry{
+		TxID:      args[0].(string),
+		AccountID: args[1].(uuid.UUID),
+		Amount:    decimal.RequireFromString(args[2].(string)),
+	})
+	return nil, nil
+}
+
+func TestPost(t *testing.T) {
+	accountID := uuid.New()
+
+	tests := []struct {
+		name   string
+		source domain.Source
+		delta  decimal.Decimal
+	}{
+		{name: "game deposit", source: domain.SourceGame, delta: decimal.NewFromFloat(10.50)},
+		{name: "payment withdraw", source: domain.SourcePayment, delta: decimal.NewFromFloat(-3.25)},
+		{name: "service deposit", source: domain.SourceService, delta: decimal.NewFromInt(7)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := &recordingExecer{}
+			if err := Post(context.Background(), tx, "tx-1", accountID, tt.source, tt.delta); err != nil {
+				t.Fatalf("Post() error = %v", err)
+			}
+			if len(tx.entries) != 2 {
+				t.Fatalf("inserted %d entries, want 2", len(tx.entries))
+			}
+
+			systemID, _ := domain.SystemAccountID(tt.source)
+			user, system := tx.entries[0], tx.entries[1]
+			if user.AccountID != accountID || !user.Amount.Equal(tt.delta) {
+				t.Errorf("account entry = %+v, want %s on %s", user, tt.delta, accountID)
+			}
+			// the counter-entry goes to the system account of the operation's source
+			if system.AccountID != systemID || !system.Amount.Equal(tt.delta.Neg()) {
+				t.Errorf("system entry = %+v, want %s on %s", system, tt.delta.Neg(), systemID)
+			}
+			if sum := user.Amount.Add(system.Amount); !sum.IsZero() {
+				t.Errorf("entries sum to %s, want 0", sum)
+			}
+		})
+	}
+}
+
+func TestPost_ZeroDelta(t *testing.T) {
+	tx := &recordingExecer{}
+	if err := Post(context.Background(), tx, "hold-1", uuid.New(), domain.SourceGame, decimal.Zero); err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	if len(tx.entries) != 0 {
+		t.Errorf("inserted %d entries for a zero delta, want 0", len(tx.entries))
+	}
+}
+
+func TestPost_UnknownSource(t *testing.T) {
+	tx := &recordingExecer{}
+	if err := Post(context.Background(), tx, "tx-1", uuid.New(), domain.Source("unknown"), decimal.NewFromInt(1)); err == nil {
+		t.Error("Post() error = nil for an unknown source, want an error")
+	}
+	if len(tx.entries) != 0 {
+		t.Errorf("inserted %d entries, want 0", len(tx.entries))
+	}
+}
+
+func TestPostEntries_Unbalanced(t *testing.T) {
+	systemID, _ := domain.SystemAccountID(domain.SourceGame)
+	entries := []domain.LedgerEntry{
+		{TxID: "tx-1", AccountID: uuid.New(), Amount: decimal.NewFromInt(10)},
+		{TxID: "tx-1", AccountID: systemID, Amount: decimal.NewFromInt(-9)},
+	}
+
+	tx := &recordingExecer{}
+	err := postEntries(context.Background(), tx, entries)
+	if !errors.Is(err, ErrUnbalanced) {
+		t.Fatalf("postEntries() error = %v, want %v", err, ErrUnbalanced)
+	}
+	if len(tx.entries) != 0 {
+		t.Errorf("inserted %d entries of an unbalanced posting, want 0", len(tx.entries))
+	}
+}
+
+func TestPost_InsertFails(t *testing.T) {
+	tx := &recordingExecer{err: errors.New("connection reset")}
+	if err := Post(context.Background(), tx, "tx-1", uuid.New(), domain.SourceGame, decimal.NewFromInt(1)); err == nil {
+		t.Error("Post() error = nil when the insert fails, want an error")
+	}
+}
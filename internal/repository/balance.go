@@ -9,10 +9,18 @@ import (
 
 	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/db"
 	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/domain"
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/events"
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/ledger"
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/MaksimPozharskiy/grpc-balance-processor/internal/repository")
+
 const (
 	sqlCreateAccount = `
 INSERT INTO accounts (id, balance) VALUES ($1, 0)
@@ -30,12 +38,70 @@ UPDATE accounts
    SET balance = balance + $1::numeric,
        updated_at = now()
  WHERE id = $2
-   AND balance + $1::numeric >= 0
-RETURNING balance, updated_at
+   AND balance - held + $1::numeric >= 0
+RETURNING balance, held, updated_at
+`
+
+	sqlSelectOperationByTxID = `
+SELECT id, tx_id, account_id, source, state, amount, created_at, applied, canceled_at, cancel_note, transfer_id
+  FROM operations
+ WHERE tx_id = $1
+`
+
+	sqlRecordOutcome = `
+UPDATE operations
+   SET applied = $2,
+       status = $3::status_t,
+       balance_after = $4::numeric,
+       available_after = $5::numeric,
+       processed_at = now()
+ WHERE tx_id = $1
+RETURNING processed_at
+`
+
+	sqlSelectOutcome = `
+SELECT status, balance_after, available_after, processed_at FROM operations WHERE tx_id = $1
 `
 
 	sqlSelectBalance = `
-SELECT balance, updated_at FROM accounts WHERE id = $1
+SELECT balance, held, updated_at FROM accounts WHERE id = $1
+`
+
+	// a canceled operation keeps counting: its cancel:: compensation is a
+	// separate applied operation created when it was canceled
+	sqlSelectBalanceAt = `
+SELECT COALESCE(s.balance, 0) + COALESCE((
+         SELECT sum(operation_delta(o.state, o.amount))
+           FROM operations o
+          WHERE o.account_id = a.id
+            AND o.applied
+            AND o.created_at >= COALESCE(s.taken_at, '-infinity'::timestamptz)
+            AND o.created_at < $2::timestamptz
+       ), 0)
+  FROM accounts a
+  LEFT JOIN LATERAL (
+       SELECT taken_at, balance
+         FROM balance_snapshots
+        WHERE account_id = a.id AND taken_at <= $2::timestamptz
+        ORDER BY taken_at DESC
+        LIMIT 1
+       ) s ON true
+ WHERE a.id = $1
+`
+
+	sqlListOperations = `
+SELECT id, tx_id, account_id, source, state, amount, created_at, applied, canceled_at, cancel_note, transfer_id
+  FROM operations
+ WHERE account_id = $1
+   AND ($2::source_t IS NULL OR source = $2::source_t)
+   AND ($3::state_t IS NULL OR state = $3::state_t)
+   AND ($4::boolean IS NULL OR applied = $4::boolean)
+   AND ($5::boolean IS NULL OR (canceled_at IS NOT NULL) = $5::boolean)
+   AND ($6::timestamptz IS NULL OR created_at >= $6::timestamptz)
+   AND ($7::timestamptz IS NULL OR created_at < $7::timestamptz)
+   AND ($8::timestamptz IS NULL OR (created_at, id) < ($8::timestamptz, $9::bigint))
+ ORDER BY created_at DESC, id DESC
+ LIMIT $10
 `
 )
 
@@ -48,13 +114,7 @@ func NewBalanceRepository(database *db.DB) *BalanceRepository {
 }
 
 func (r *BalanceRepository) GetAccount(ctx context.Context, accountID uuid.UUID) (*domain.Account, error) {
-	query := `SELECT id, balance, updated_at FROM accounts WHERE id = $1`
-
-	var acc domain.Account
-	var balanceStr string
-	err := r.db.QueryRowContext(ctx, query, accountID).Scan(
-		&acc.ID, &balanceStr, &acc.UpdatedAt,
-	)
+	acc, err := scanAccount(r.db.QueryRowContext(ctx, sqlSelectBalance, accountID), accountID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, fmt.Errorf("get account: %w", domain.ErrNotFound)
@@ -62,13 +122,21 @@ func (r *BalanceRepository) GetAccount(ctx context.Context, accountID uuid.UUID)
 		return nil, fmt.Errorf("get account query: %w", err)
 	}
 
-	balance, err := decimal.NewFromString(balanceStr)
-	if err != nil {
-		return nil, fmt.Errorf("parse balance: %w", err)
+	return acc, nil
+}
+
+// GetBalanceAt rebuilds the balance from operations created before at,
+// starting from the latest snapshot taken no later than at.
+func (r *BalanceRepository) GetBalanceAt(ctx context.Context, accountID uuid.UUID, at time.Time) (decimal.Decimal, error) {
+	var balance decimal.Decimal
+	if err := r.db.QueryRowContext(ctx, sqlSelectBalanceAt, accountID, at).Scan(&balance); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return decimal.Zero, fmt.Errorf("get balance at: %w", domain.ErrNotFound)
+		}
+		return decimal.Zero, fmt.Errorf("get balance at query: %w", err)
 	}
-	acc.Balance = balance
 
-	return &acc, nil
+	return balance, nil
 }
 
 func (r *BalanceRepository) CreateAccount(ctx context.Context, accountID uuid.UUID) error {
@@ -83,20 +151,14 @@ func (r *BalanceRepository) CreateAccount(ctx context.Context, accountID uuid.UU
 }
 
 func (r *BalanceRepository) GetOperationByTxID(ctx context.Context, txID string) (*domain.Operation, error) {
-	query := `
-		SELECT id, tx_id, account_id, source, state, amount, created_at, applied, canceled_at, cancel_note
-		FROM operations
-		WHERE tx_id = $1
-	`
-
 	var op domain.Operation
-	err := r.db.QueryRowContext(ctx, query, txID).Scan(
+	err := r.db.QueryRowContext(ctx, sqlSelectOperationByTxID, txID).Scan(
 		&op.ID, &op.TxID, &op.AccountID, &op.Source, &op.State, &op.Amount,
-		&op.CreatedAt, &op.Applied, &op.CanceledAt, &op.CancelNote,
+		&op.CreatedAt, &op.Applied, &op.CanceledAt, &op.CancelNote, &op.TransferID,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("get operation by tx_id: %w", domain.ErrNotFound)
+			return nil, fmt.Errorf("get operation by tx_id: %w", domain.ErrOperationNotFound)
 		}
 		return nil, fmt.Errorf("get operation query: %w", err)
 	}
@@ -104,20 +166,120 @@ func (r *BalanceRepository) GetOperationByTxID(ctx context.Context, txID string)
 	return &op, nil
 }
 
-func selectAccount(ctx context.Context, tx *sql.Tx, id uuid.UUID) (*domain.Account, error) {
-	var s string
+func (r *BalanceRepository) ListOperations(ctx context.Context, filter domain.OperationFilter, after *domain.OperationCursor, limit int) ([]*domain.Operation, error) {
+	var source, state, afterCreatedAt, afterID any
+	if filter.Source != nil {
+		source = string(*filter.Source)
+	}
+	if filter.State != nil {
+		state = string(*filter.State)
+	}
+	if after != nil {
+		afterCreatedAt, afterID = after.CreatedAt, after.ID
+	}
+
+	rows, err := r.db.QueryContext(ctx, sqlListOperations,
+		filter.AccountID,
+		source,
+		state,
+		nullable(filter.Applied),
+		nullable(filter.Canceled),
+		nullable(filter.CreatedFrom),
+		nullable(filter.CreatedTo),
+		afterCreatedAt, afterID,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list operations query: %w", err)
+	}
+	defer rows.Close()
+
+	ops := make([]*domain.Operation, 0, limit)
+	for rows.Next() {
+		var op domain.Operation
+		if err := rows.Scan(
+			&op.ID, &op.TxID, &op.AccountID, &op.Source, &op.State, &op.Amount,
+			&op.CreatedAt, &op.Applied, &op.CanceledAt, &op.CancelNote, &op.TransferID,
+		); err != nil {
+			return nil, fmt.Errorf("scan operation: %w", err)
+		}
+		ops = append(ops, &op)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate operations: %w", err)
+	}
+
+	return ops, nil
+}
+
+// nullable turns an optional filter value into a SQL parameter, NULL when unset.
+func nullable[T any](v *T) any {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+func selectOperation(ctx context.Context, tx *sql.Tx, txID string) (*domain.Operation, error) {
+	var op domain.Operation
+	if err := tx.QueryRowContext(ctx, sqlSelectOperationByTxID, txID).Scan(
+		&op.ID, &op.TxID, &op.AccountID, &op.Source, &op.State, &op.Amount,
+		&op.CreatedAt, &op.Applied, &op.CanceledAt, &op.CancelNote, &op.TransferID,
+	); err != nil {
+		return nil, err
+	}
+	return &op, nil
+}
+
+// scanAccount reads a (balance, held, updated_at) row.
+func scanAccount(row *sql.Row, id uuid.UUID) (*domain.Account, error) {
+	var balanceStr, heldStr string
 	var t time.Time
-	if err := tx.QueryRowContext(ctx, sqlSelectBalance, id).Scan(&s, &t); err != nil {
+	if err := row.Scan(&balanceStr, &heldStr, &t); err != nil {
 		return nil, err
 	}
-	bal, err := decimal.NewFromString(s)
+
+	balance, err := decimal.NewFromString(balanceStr)
 	if err != nil {
 		return nil, fmt.Errorf("parse balance: %w", err)
 	}
-	return &domain.Account{ID: id, Balance: bal, UpdatedAt: t}, nil
+	held, err := decimal.NewFromString(heldStr)
+	if err != nil {
+		return nil, fmt.Errorf("parse held: %w", err)
+	}
+
+	return &domain.Account{ID: id, Balance: balance, Held: held, UpdatedAt: t}, nil
+}
+
+func selectAccount(ctx context.Context, tx *sql.Tx, id uuid.UUID) (*domain.Account, error) {
+	return scanAccount(tx.QueryRowContext(ctx, sqlSelectBalance, id), id)
+}
+
+// updateBalance applies delta with the non-negative guard of sqlUpdateBalance.
+// Funds reserved by holds cannot be spent.
+func updateBalance(ctx context.Context, tx *sql.Tx, accountID uuid.UUID, delta decimal.Decimal) (*domain.Account, error) {
+	acc, err := scanAccount(tx.QueryRowContext(ctx, sqlUpdateBalance, delta.String(), accountID), accountID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrNegativeBalance
+		}
+		return nil, fmt.Errorf("update balance: %w", err)
+	}
+	return acc, nil
 }
 
-func (r *BalanceRepository) ProcessTransaction(ctx context.Context, op *domain.Operation) (*domain.Account, error) {
+func (r *BalanceRepository) ProcessTransaction(ctx context.Context, op *domain.Operation) (_ *domain.ProcessOutcome, err error) {
+	// the statements below get their spans from the driver
+	ctx, span := tracer.Start(ctx, "BalanceRepository.ProcessTransaction",
+		trace.WithAttributes(attribute.String("tx_id", op.TxID)))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, fmt.Errorf("begin: %w", err)
@@ -126,6 +288,20 @@ func (r *BalanceRepository) ProcessTransaction(ctx context.Context, op *domain.O
 		_ = tx.Rollback() // main error is more important
 	}()
 
+	outcome, err := processInTx(ctx, tx, op)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+
+	return outcome, nil
+}
+
+// processInTx applies a single deposit or withdraw inside tx. A rejected
+// operation is stored with its outcome rather than returned as an error.
+func processInTx(ctx context.Context, tx *sql.Tx, op *domain.Operation) (*domain.ProcessOutcome, error) {
 	// creating account
 	if _, err := tx.ExecContext(ctx, sqlCreateAccount, op.AccountID); err != nil {
 		return nil, fmt.Errorf("create account: %w", err)
@@ -139,15 +315,7 @@ func (r *BalanceRepository) ProcessTransaction(ctx context.Context, op *domain.O
 	}
 
 	if rows, _ := res.RowsAffected(); rows == 0 {
-		// if operation exist - just return current balance
-		acc, err := selectAccount(ctx, tx, op.AccountID)
-		if err != nil {
-			return nil, fmt.Errorf("select balance (dup): %w", err)
-		}
-		if err := tx.Commit(); err != nil {
-			return nil, fmt.Errorf("commit (dup): %w", err)
-		}
-		return acc, domain.ErrDuplicateTx
+		return replayOutcome(ctx, tx, op)
 	}
 
 	// compute delta for the balance update
@@ -157,26 +325,112 @@ func (r *BalanceRepository) ProcessTransaction(ctx context.Context, op *domain.O
 	}
 
 	// appply delta with non-negative constraint
-	var s string
-	var t time.Time
-	if err := tx.QueryRowContext(ctx, sqlUpdateBalance, delta.String(), op.AccountID).Scan(&s, &t); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, domain.ErrNegativeBalance
+	acc, err := updateBalance(ctx, tx, op.AccountID, delta)
+	if err != nil {
+		if !errors.Is(err, domain.ErrNegativeBalance) {
+			return nil, err
 		}
-		return nil, fmt.Errorf("update balance: %w", err)
+
+		// keep the rejected operation so a retry gets the same answer
+		acc, err := selectAccount(ctx, tx, op.AccountID)
+		if err != nil {
+			return nil, fmt.Errorf("select balance (rejected): %w", err)
+		}
+		return recordOutcome(ctx, tx, op.TxID, false, domain.StatusRejectedNegative, acc)
 	}
-	bal, err := decimal.NewFromString(s)
+
+	// operation successful
+	if err := ledger.Post(ctx, tx, op.TxID, op.AccountID, op.Source, delta); err != nil {
+		return nil, err
+	}
+	if err := events.PublishBalance(ctx, tx, domain.NewBalanceEvent(acc, op.TxID)); err != nil {
+		return nil, err
+	}
+	return recordOutcome(ctx, tx, op.TxID, true, domain.StatusOK, acc)
+}
+
+// replayOutcome returns the stored outcome for an already inserted tx_id.
+func replayOutcome(ctx context.Context, tx *sql.Tx, op *domain.Operation) (*domain.ProcessOutcome, error) {
+	// tx_id must not be reused for a different payment
+	stored, err := selectOperation(ctx, tx, op.TxID)
 	if err != nil {
-		return nil, fmt.Errorf("parse updated balance: %w", err)
+		return nil, fmt.Errorf("select operation (dup): %w", err)
+	}
+	if !stored.SamePayload(op) {
+		return nil, domain.ErrIdempotencyConflict
 	}
 
-	// operation successful
-	if _, err := tx.ExecContext(ctx, `UPDATE operations SET applied = true WHERE tx_id = $1`, op.TxID); err != nil {
-		return nil, fmt.Errorf("mark applied: %w", err)
+	var status sql.NullString
+	var balance, available decimal.NullDecimal
+	var processedAt sql.NullTime
+	if err := tx.QueryRowContext(ctx, sqlSelectOutcome, op.TxID).Scan(&status, &balance, &available, &processedAt); err != nil {
+		return nil, fmt.Errorf("select outcome (dup): %w", err)
 	}
-	if err := tx.Commit(); err != nil {
-		return nil, fmt.Errorf("commit: %w", err)
+
+	if !status.Valid {
+		// operation stored before outcomes were recorded - fall back to current balance
+		acc, err := selectAccount(ctx, tx, op.AccountID)
+		if err != nil {
+			return nil, fmt.Errorf("select balance (dup): %w", err)
+		}
+		return &domain.ProcessOutcome{
+			Status:      domain.StatusAlreadyProcessed,
+			Balance:     acc.Balance,
+			Available:   acc.Available(),
+			ProcessedAt: acc.UpdatedAt,
+			Replayed:    true,
+		}, nil
+	}
+
+	st, err := parseOutcomeStatus(status.String)
+	if err != nil {
+		return nil, err
+	}
+
+	// outcomes stored before holds existed have no available balance; nothing was held then
+	if !available.Valid {
+		available = balance
 	}
 
-	return &domain.Account{ID: op.AccountID, Balance: bal, UpdatedAt: t}, nil
+	return &domain.ProcessOutcome{
+		Status:      st,
+		Balance:     balance.Decimal,
+		Available:   available.Decimal,
+		ProcessedAt: processedAt.Time,
+		Replayed:    true,
+	}, nil
+}
+
+func recordOutcome(ctx context.Context, tx *sql.Tx, txID string, applied bool, status domain.ProcessStatus, acc *domain.Account) (*domain.ProcessOutcome, error) {
+	var processedAt time.Time
+	if err := tx.QueryRowContext(ctx, sqlRecordOutcome,
+		txID, applied, formatOutcomeStatus(status), acc.Balance.String(), acc.Available().String(),
+	).Scan(&processedAt); err != nil {
+		return nil, fmt.Errorf("record outcome: %w", err)
+	}
+
+	return &domain.ProcessOutcome{
+		Status:      status,
+		Balance:     acc.Balance,
+		Available:   acc.Available(),
+		ProcessedAt: processedAt,
+	}, nil
+}
+
+func formatOutcomeStatus(s domain.ProcessStatus) string {
+	if s == domain.StatusRejectedNegative {
+		return "rejected_negative"
+	}
+	return "ok"
+}
+
+func parseOutcomeStatus(s string) (domain.ProcessStatus, error) {
+	switch s {
+	case "ok":
+		return domain.StatusOK, nil
+	case "rejected_negative":
+		return domain.StatusRejectedNegative, nil
+	default:
+		return 0, fmt.Errorf("unknown outcome status %q", s)
+	}
 }
@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/domain"
+	"github.com/google/uuid"
+)
+
+// ProcessBatchAtomic applies all operations in one transaction. Any error or
+// rejection rolls the whole batch back and is reported as a *domain.BatchItemError.
+func (r *BalanceRepository) ProcessBatchAtomic(ctx context.Context, ops []*domain.Operation) ([]*domain.ProcessOutcome, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback() // main error is more important
+	}()
+
+	// every account row is locked once, up front, in a deterministic order
+	if err := lockAccounts(ctx, tx, batchAccountIDs(ops)...); err != nil {
+		return nil, err
+	}
+
+	outcomes := make([]*domain.ProcessOutcome, len(ops))
+	for i, op := range ops {
+		outcome, err := processInTx(ctx, tx, op)
+		if err != nil {
+			return nil, &domain.BatchItemError{Index: i, TxID: op.TxID, Err: err}
+		}
+		if outcome.Status == domain.StatusRejectedNegative {
+			return nil, &domain.BatchItemError{Index: i, TxID: op.TxID, Err: domain.ErrNegativeBalance}
+		}
+		outcomes[i] = outcome
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+
+	return outcomes, nil
+}
+
+// ProcessBatch applies operations independently. Operations are grouped by
+// account and each group runs in its own transaction, in request order.
+func (r *BalanceRepository) ProcessBatch(ctx context.Context, ops []*domain.Operation) []domain.BatchItemOutcome {
+	results := make([]domain.BatchItemOutcome, len(ops))
+	for _, group := range groupByAccount(ops) {
+		r.processAccountGroup(ctx, ops, group, results)
+	}
+	return results
+}
+
+// processAccountGroup applies ops[idx...], all on the same account, writing into results.
+func (r *BalanceRepository) processAccountGroup(ctx context.Context, ops []*domain.Operation, idx []int, results []domain.BatchItemOutcome) {
+	failAll := func(err error) {
+		for _, i := range idx {
+			results[i] = domain.BatchItemOutcome{Err: err}
+		}
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		failAll(fmt.Errorf("begin: %w", err))
+		return
+	}
+	defer func() {
+		_ = tx.Rollback() // main error is more important
+	}()
+
+	if err := lockAccounts(ctx, tx, ops[idx[0]].AccountID); err != nil {
+		failAll(err)
+		return
+	}
+
+	for _, i := range idx {
+		// a failed item must not abort the rest of the group
+		if _, err := tx.ExecContext(ctx, "SAVEPOINT batch_item"); err != nil {
+			failAll(fmt.Errorf("savepoint: %w", err))
+			return
+		}
+
+		outcome, err := processInTx(ctx, tx, ops[i])
+		if err != nil {
+			results[i] = domain.BatchItemOutcome{Err: err}
+			if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT batch_item"); err != nil {
+				failAll(fmt.Errorf("rollback to savepoint: %w", err))
+				return
+			}
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT batch_item"); err != nil {
+			failAll(fmt.Errorf("release savepoint: %w", err))
+			return
+		}
+		results[i] = domain.BatchItemOutcome{Outcome: outcome}
+	}
+
+	if err := tx.Commit(); err != nil {
+		failAll(fmt.Errorf("commit: %w", err))
+	}
+}
+
+// groupByAccount returns indexes of ops per account, groups ordered by first appearance.
+func groupByAccount(ops []*domain.Operation) [][]int {
+	pos := make(map[uuid.UUID]int)
+	var groups [][]int
+	for i, op := range ops {
+		g, ok := pos[op.AccountID]
+		if !ok {
+			g = len(groups)
+			pos[op.AccountID] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], i)
+	}
+	return groups
+}
+
+func batchAccountIDs(ops []*domain.Operation) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(ops))
+	for _, op := range ops {
+		ids = append(ids, op.AccountID)
+	}
+	return ids
+}
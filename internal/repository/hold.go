@@ -0,0 +1,269 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/domain"
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/events"
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/ledger"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	sqlReserveFunds = `
+UPDATE accounts
+   SET held = held + $1::numeric,
+       updated_at = now()
+ WHERE id = $2
+   AND balance - held - $1::numeric >= 0
+RETURNING balance, held, updated_at
+`
+
+	sqlInsertHold = `
+INSERT INTO holds (tx_id, account_id, amount, expires_at)
+VALUES ($1, $2, $3::numeric, now() + $4::bigint * interval '1 second')
+RETURNING expires_at
+`
+
+	sqlSelectHold = `
+SELECT h.tx_id, h.account_id, o.source, h.amount, h.captured, h.expires_at, h.closed_at, h.closed_by,
+       h.expires_at <= now() AS expired
+  FROM holds h
+  JOIN operations o ON o.tx_id = h.tx_id
+ WHERE h.tx_id = $1
+`
+
+	sqlSettleHold = `
+UPDATE accounts
+   SET balance = balance - $1::numeric,
+       held = held - $2::numeric,
+       updated_at = now()
+ WHERE id = $3
+RETURNING balance, held, updated_at
+`
+
+	sqlCloseHold = `
+UPDATE holds
+   SET captured = $2::numeric,
+       closed_at = now(),
+       closed_by = $3
+ WHERE tx_id = $1
+RETURNING closed_at
+`
+)
+
+func (r *BalanceRepository) Reserve(ctx context.Context, op *domain.Operation, ttl time.Duration) (*domain.HoldOutcome, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback() // main error is more important
+	}()
+
+	if _, err := tx.ExecContext(ctx, sqlCreateAccount, op.AccountID); err != nil {
+		return nil, fmt.Errorf("create account: %w", err)
+	}
+
+	res, err := tx.ExecContext(ctx, sqlInsertOperation,
+		op.TxID, op.AccountID, string(op.Source), string(op.State), op.Amount.String(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("insert op: %w", err)
+	}
+
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		outcome, err := replayOutcome(ctx, tx, op)
+		if err != nil {
+			return nil, err
+		}
+		// a rejected reserve has no hold
+		hold, _, err := selectHold(ctx, tx, op.TxID, false)
+		if err != nil && !errors.Is(err, domain.ErrHoldNotFound) {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("commit (dup): %w", err)
+		}
+		return &domain.HoldOutcome{ProcessOutcome: *outcome, Hold: hold}, nil
+	}
+
+	// reserve against the available balance
+	acc, err := scanAccount(tx.QueryRowContext(ctx, sqlReserveFunds, op.Amount.String(), op.AccountID), op.AccountID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("reserve funds: %w", err)
+		}
+
+		// keep the rejected operation so a retry gets the same answer
+		acc, err := selectAccount(ctx, tx, op.AccountID)
+		if err != nil {
+			return nil, fmt.Errorf("select balance (rejected): %w", err)
+		}
+		outcome, err := recordOutcome(ctx, tx, op.TxID, false, domain.StatusRejectedNegative, acc)
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("commit (rejected): %w", err)
+		}
+		return &domain.HoldOutcome{ProcessOutcome: *outcome}, nil
+	}
+
+	var expiresAt time.Time
+	if err := tx.QueryRowContext(ctx, sqlInsertHold,
+		op.TxID, op.AccountID, op.Amount.String(), int64(ttl/time.Second),
+	).Scan(&expiresAt); err != nil {
+		return nil, fmt.Errorf("insert hold: %w", err)
+	}
+
+	outcome, err := recordOutcome(ctx, tx, op.TxID, true, domain.StatusOK, acc)
+	if err != nil {
+		return nil, err
+	}
+	if err := events.PublishBalance(ctx, tx, domain.NewBalanceEvent(acc, op.TxID)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+
+	return &domain.HoldOutcome{
+		ProcessOutcome: *outcome,
+		Hold: &domain.Hold{
+			TxID:      op.TxID,
+			AccountID: op.AccountID,
+			Source:    op.Source,
+			Amount:    op.Amount,
+			Captured:  decimal.Zero,
+			ExpiresAt: expiresAt,
+		},
+	}, nil
+}
+
+// Capture settles op.Amount of the hold (all of it when zero) and releases the rest.
+func (r *BalanceRepository) Capture(ctx context.Context, holdTxID string, op *domain.Operation) (*domain.HoldOutcome, error) {
+	op.State = domain.StateCapture
+	return r.closeHold(ctx, holdTxID, op)
+}
+
+// Release returns the whole hold to the available balance.
+func (r *BalanceRepository) Release(ctx context.Context, holdTxID string, op *domain.Operation) (*domain.HoldOutcome, error) {
+	op.State = domain.StateRelease
+	op.Amount = decimal.Zero
+	return r.closeHold(ctx, holdTxID, op)
+}
+
+func (r *BalanceRepository) closeHold(ctx context.Context, holdTxID string, op *domain.Operation) (*domain.HoldOutcome, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback() // main error is more important
+	}()
+
+	hold, expired, err := selectHold(ctx, tx, holdTxID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// the operation inherits account and source of the hold
+	op.AccountID = hold.AccountID
+	op.Source = hold.Source
+	if op.Amount.IsZero() {
+		op.Amount = hold.Amount
+	}
+
+	res, err := tx.ExecContext(ctx, sqlInsertOperation,
+		op.TxID, op.AccountID, string(op.Source), string(op.State), op.Amount.String(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("insert op: %w", err)
+	}
+
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		outcome, err := replayOutcome(ctx, tx, op)
+		if err != nil {
+			return nil, err
+		}
+		if hold.ClosedBy == nil || *hold.ClosedBy != op.TxID {
+			return nil, domain.ErrIdempotencyConflict
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("commit (dup): %w", err)
+		}
+		return &domain.HoldOutcome{ProcessOutcome: *outcome, Hold: hold}, nil
+	}
+
+	if hold.ClosedAt != nil || expired {
+		return nil, domain.ErrHoldClosed
+	}
+	if op.Amount.GreaterThan(hold.Amount) {
+		return nil, domain.ErrCaptureExceedsHold
+	}
+
+	captured := decimal.Zero
+	if op.State == domain.StateCapture {
+		captured = op.Amount
+	}
+
+	acc, err := scanAccount(tx.QueryRowContext(ctx, sqlSettleHold,
+		captured.String(), hold.Amount.String(), hold.AccountID,
+	), hold.AccountID)
+	if err != nil {
+		return nil, fmt.Errorf("settle hold: %w", err)
+	}
+
+	var closedAt time.Time
+	if err := tx.QueryRowContext(ctx, sqlCloseHold, holdTxID, captured.String(), op.TxID).Scan(&closedAt); err != nil {
+		return nil, fmt.Errorf("close hold: %w", err)
+	}
+
+	// only a capture moves money; a release posts nothing
+	if err := ledger.Post(ctx, tx, op.TxID, hold.AccountID, hold.Source, captured.Neg()); err != nil {
+		return nil, err
+	}
+	hold.Captured = captured
+	hold.ClosedAt = &closedAt
+	hold.ClosedBy = &op.TxID
+
+	outcome, err := recordOutcome(ctx, tx, op.TxID, true, domain.StatusOK, acc)
+	if err != nil {
+		return nil, err
+	}
+	if err := events.PublishBalance(ctx, tx, domain.NewBalanceEvent(acc, op.TxID)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+
+	return &domain.HoldOutcome{ProcessOutcome: *outcome, Hold: hold}, nil
+}
+
+// selectHold loads a hold and whether it has expired, optionally locking it.
+func selectHold(ctx context.Context, tx *sql.Tx, txID string, forUpdate bool) (*domain.Hold, bool, error) {
+	query := sqlSelectHold
+	if forUpdate {
+		query += " FOR UPDATE OF h"
+	}
+
+	var hold domain.Hold
+	var expired bool
+	if err := tx.QueryRowContext(ctx, query, txID).Scan(
+		&hold.TxID, &hold.AccountID, &hold.Source, &hold.Amount, &hold.Captured,
+		&hold.ExpiresAt, &hold.ClosedAt, &hold.ClosedBy, &expired,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, fmt.Errorf("select hold: %w", domain.ErrHoldNotFound)
+		}
+		return nil, false, fmt.Errorf("select hold: %w", err)
+	}
+
+	return &hold, expired, nil
+}
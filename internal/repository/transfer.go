@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/domain"
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/events"
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/ledger"
+	"github.com/google/uuid"
+)
+
+const (
+	sqlInsertTransferLeg = `
+INSERT INTO operations (tx_id, account_id, source, state, amount, transfer_id)
+VALUES ($1, $2, $3::source_t, $4::state_t, $5::numeric, $6)
+ON CONFLICT (tx_id) DO NOTHING
+`
+
+	sqlLockAccount = `
+SELECT id FROM accounts WHERE id = $1 FOR UPDATE
+`
+)
+
+func (r *BalanceRepository) Transfer(ctx context.Context, t *domain.Transfer) (*domain.TransferOutcome, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback() // main error is more important
+	}()
+
+	if err := lockAccounts(ctx, tx, t.FromAccountID, t.ToAccountID); err != nil {
+		return nil, err
+	}
+
+	debit, credit := t.Legs()
+
+	inserted, err := insertTransferLeg(ctx, tx, debit)
+	if err != nil {
+		return nil, err
+	}
+	if !inserted {
+		outcome, err := replayTransfer(ctx, tx, debit, credit)
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("commit (dup): %w", err)
+		}
+		return outcome, nil
+	}
+
+	inserted, err = insertTransferLeg(ctx, tx, credit)
+	if err != nil {
+		return nil, err
+	}
+	if !inserted {
+		// credit tx_id is taken by an operation that is not part of this transfer
+		return nil, domain.ErrIdempotencyConflict
+	}
+
+	// debit first: it is the only leg the non-negative guard can reject
+	from, err := updateBalance(ctx, tx, t.FromAccountID, t.Amount.Neg())
+	if err != nil {
+		if !errors.Is(err, domain.ErrNegativeBalance) {
+			return nil, err
+		}
+
+		outcome, err := rejectTransfer(ctx, tx, debit, credit)
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("commit (rejected): %w", err)
+		}
+		return outcome, nil
+	}
+
+	to, err := updateBalance(ctx, tx, t.ToAccountID, t.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ledger.Post(ctx, tx, debit.TxID, t.FromAccountID, t.Source, t.Amount.Neg()); err != nil {
+		return nil, err
+	}
+	if err := ledger.Post(ctx, tx, credit.TxID, t.ToAccountID, t.Source, t.Amount); err != nil {
+		return nil, err
+	}
+
+	debitOutcome, err := recordOutcome(ctx, tx, debit.TxID, true, domain.StatusOK, from)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := recordOutcome(ctx, tx, credit.TxID, true, domain.StatusOK, to); err != nil {
+		return nil, err
+	}
+	if err := events.PublishBalance(ctx, tx, domain.NewBalanceEvent(from, debit.TxID)); err != nil {
+		return nil, err
+	}
+	if err := events.PublishBalance(ctx, tx, domain.NewBalanceEvent(to, credit.TxID)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+
+	return &domain.TransferOutcome{
+		Status:      domain.StatusOK,
+		FromBalance: from.Balance,
+		ToBalance:   to.Balance,
+		ProcessedAt: debitOutcome.ProcessedAt,
+	}, nil
+}
+
+// lockAccounts creates missing accounts and locks their rows in a deterministic order.
+func lockAccounts(ctx context.Context, tx *sql.Tx, ids ...uuid.UUID) error {
+	for _, id := range domain.AccountLockOrder(ids...) {
+		if _, err := tx.ExecContext(ctx, sqlCreateAccount, id); err != nil {
+			return fmt.Errorf("create account: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, sqlLockAccount, id); err != nil {
+			return fmt.Errorf("lock account: %w", err)
+		}
+	}
+	return nil
+}
+
+func insertTransferLeg(ctx context.Context, tx *sql.Tx, leg *domain.Operation) (bool, error) {
+	res, err := tx.ExecContext(ctx, sqlInsertTransferLeg,
+		leg.TxID, leg.AccountID, string(leg.Source), string(leg.State), leg.Amount.String(), *leg.TransferID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("insert transfer leg: %w", err)
+	}
+
+	rows, _ := res.RowsAffected()
+	return rows > 0, nil
+}
+
+func rejectTransfer(ctx context.Context, tx *sql.Tx, debit, credit *domain.Operation) (*domain.TransferOutcome, error) {
+	from, err := selectAccount(ctx, tx, debit.AccountID)
+	if err != nil {
+		return nil, fmt.Errorf("select balance (rejected): %w", err)
+	}
+	to, err := selectAccount(ctx, tx, credit.AccountID)
+	if err != nil {
+		return nil, fmt.Errorf("select balance (rejected): %w", err)
+	}
+
+	debitOutcome, err := recordOutcome(ctx, tx, debit.TxID, false, domain.StatusRejectedNegative, from)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := recordOutcome(ctx, tx, credit.TxID, false, domain.StatusRejectedNegative, to); err != nil {
+		return nil, err
+	}
+
+	return &domain.TransferOutcome{
+		Status:      domain.StatusRejectedNegative,
+		FromBalance: from.Balance,
+		ToBalance:   to.Balance,
+		ProcessedAt: debitOutcome.ProcessedAt,
+	}, nil
+}
+
+func replayTransfer(ctx context.Context, tx *sql.Tx, debit, credit *domain.Operation) (*domain.TransferOutcome, error) {
+	debitOutcome, err := replayOutcome(ctx, tx, debit)
+	if err != nil {
+		return nil, err
+	}
+	creditOutcome, err := replayOutcome(ctx, tx, credit)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrIdempotencyConflict
+		}
+		return nil, err
+	}
+
+	return &domain.TransferOutcome{
+		Status:      debitOutcome.Status,
+		FromBalance: debitOutcome.Balance,
+		ToBalance:   creditOutcome.Balance,
+		ProcessedAt: debitOutcome.ProcessedAt,
+		Replayed:    true,
+	}, nil
+}
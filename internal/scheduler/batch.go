@@ -0,0 +1,366 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/domain"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// BatchConfig sizes the work of a cancellation cycle. A cycle selects up to
+// MaxCandidates candidates when it starts. Each of Workers takes Size of
+// them, after any due retries, per transaction and keeps taking batches until
+// none are left or Budget has passed since the cycle started; zero Budget
+// means no limit.
+type BatchConfig struct {
+	Size          int
+	Budget        time.Duration
+	Workers       int
+	MaxCandidates int
+}
+
+// batchOutcome is what a batch did with one candidate.
+type batchOutcome struct {
+	candidate CandidateOperation
+	result    CancelResult
+	parked    bool
+}
+
+// cycleRun is the state the workers of one cycle share.
+type cycleRun struct {
+	deadline time.Time
+
+	mu         sync.Mutex
+	run        *domain.SchedulerRun
+	pending    []CandidateOperation
+	seen       map[int64]bool
+	overBudget bool
+}
+
+// newCycleRun starts a cycle over the candidates its strategy selected.
+func newCycleRun(run *domain.SchedulerRun, deadline time.Time, pending []CandidateOperation) *cycleRun {
+	return &cycleRun{run: run, deadline: deadline, pending: pending, seen: make(map[int64]bool)}
+}
+
+// take removes up to n of the selected candidates no batch has taken yet.
+func (c *cycleRun) take(n int) []CandidateOperation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n = min(n, len(c.pending))
+	taken := c.pending[:n:n]
+	c.pending = c.pending[n:]
+	return taken
+}
+
+// claim returns the candidates not attempted earlier in the cycle and marks
+// them attempted, so a candidate that could not be queued for a retry is not
+// picked up again by the next batch.
+func (c *cycleRun) claim(candidates []CandidateOperation) []CandidateOperation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	claimed := candidates[:0:0]
+	for _, candidate := range candidates {
+		if c.seen[candidate.ID] {
+			continue
+		}
+		c.seen[candidate.ID] = true
+		claimed = append(claimed, candidate)
+	}
+	return claimed
+}
+
+// expired reports whether the budget is used up at now, and remembers it.
+func (c *cycleRun) expired(now time.Time) bool {
+	if c.deadline.IsZero() || now.Before(c.deadline) {
+		return false
+	}
+
+	c.mu.Lock()
+	c.overBudget = true
+	c.mu.Unlock()
+	return true
+}
+
+// selectBatch locks due retries first, then candidates the cycle selected at
+// its start, size in all. Selected candidates that turn out to be locked or
+// no longer candidates are dropped; the next cycle selects them again if
+// needed. The strategy never returns queued operations, so the two are disjoint.
+func (s *Scheduler) selectBatch(ctx context.Context, q Querier, c *cycleRun, size int) (candidates []CandidateOperation, retries int, err error) {
+	candidates, err = selectDueRetries(ctx, q, size, true)
+	if err != nil {
+		return nil, 0, fmt.Errorf("select due retries: %w", err)
+	}
+	retries = len(candidates)
+
+	for len(candidates) < size {
+		taken := c.take(size - len(candidates))
+		if len(taken) == 0 {
+			break
+		}
+		locked, err := lockCandidates(ctx, q, taken)
+		if err != nil {
+			return nil, 0, fmt.Errorf("lock candidates: %w", err)
+		}
+		candidates = append(candidates, locked...)
+	}
+	return candidates, retries, nil
+}
+
+// batchFunc runs one batch of a cycle; see cancelBatch.
+type batchFunc func(ctx context.Context, c *cycleRun) ([]CandidateOperation, []batchOutcome, error)
+
+// drain runs batches until none are left, the budget is used up or ctx is done.
+func (s *Scheduler) drain(ctx, history context.Context, c *cycleRun, batch batchFunc) {
+	for ctx.Err() == nil && !c.expired(s.clock.Now()) {
+		claimed, outcomes, err := batch(ctx, c)
+		if err != nil {
+			s.log.Error("cancellation batch failed",
+				zap.Int("candidates", len(claimed)),
+				zap.Error(err))
+
+			// nothing of the batch was committed
+			outcomes = outcomes[:0]
+			for _, candidate := range claimed {
+				outcomes = append(outcomes, batchOutcome{
+					candidate: candidate,
+					result:    CancelResultFailed,
+					parked:    s.scheduleRetry(ctx, candidate, RetryReasonFailed),
+				})
+			}
+		}
+
+		for _, outcome := range outcomes {
+			s.tally(history, c, outcome)
+		}
+		if err != nil || len(claimed) == 0 {
+			return
+		}
+	}
+}
+
+// cancelBatch cancels a batch of candidates in one transaction. The selected
+// operations stay locked until it ends, so other workers skip them. Claimed
+// candidates are returned even on error; then none of them was cancelled.
+// Once ctx is done the batch commits what it has attempted.
+func (s *Scheduler) cancelBatch(ctx context.Context, c *cycleRun) (_ []CandidateOperation, _ []batchOutcome, err error) {
+	ctx, span := tracer.Start(ctx, "cancel_scheduler.batch")
+
+	// a started batch runs to its commit even when the cycle is stopped, so a
+	// shutdown or lost leadership never cuts a cancellation off mid-flight;
+	// the locks taken below keep another leader off the same operations.
+	// stop only decides when to stop taking candidates.
+	stop := ctx
+	ctx = context.WithoutCancel(ctx)
+
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("begin: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			s.log.Error("failed to rollback transaction", zap.Error(err))
+		}
+	}()
+
+	selected, retries, err := s.selectBatch(ctx, tx, c, s.batch.Size)
+	if err != nil {
+		return nil, nil, err
+	}
+	candidates := c.claim(selected)
+	if len(candidates) == 0 {
+		return nil, nil, nil
+	}
+
+	s.log.Debug("cancellation batch selected",
+		zap.Int("candidates", len(candidates)),
+		zap.Int("retries", retries))
+	span.SetAttributes(attribute.Int("candidates", len(candidates)), attribute.Int("retries", retries))
+
+	outcomes := make([]batchOutcome, len(candidates))
+	legs := make([][]*domain.Operation, len(candidates))
+	var batchLegs []*domain.Operation
+	for i, candidate := range candidates {
+		outcomes[i].candidate = candidate
+
+		failure, err := withSavepoint(ctx, tx, func() error {
+			operation, err := s.loadOperation(ctx, tx, candidate.ID, false)
+			if err != nil {
+				return fmt.Errorf("load operation: %w", err)
+			}
+			legs[i], err = s.lockLegs(ctx, tx, operation)
+			if err != nil {
+				return fmt.Errorf("lock operation legs: %w", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return candidates, nil, err
+		}
+		if failure != nil {
+			s.log.Error("failed to load operation for cancellation", zap.Int64("op_id", candidate.ID), zap.Error(failure))
+			legs[i] = nil
+			outcomes[i].result = CancelResultFailed
+			continue
+		}
+		batchLegs = append(batchLegs, legs[i]...)
+	}
+
+	// all accounts of the batch in one order, so concurrent batches cannot deadlock
+	if err := s.lockAccounts(ctx, tx, batchLegs); err != nil {
+		return candidates, nil, fmt.Errorf("lock accounts: %w", err)
+	}
+
+	attempted := 0
+	for i, candidate := range candidates {
+		if stop.Err() != nil || c.expired(s.clock.Now()) {
+			break
+		}
+		attempted++
+
+		if err := s.attemptCandidate(ctx, tx, candidate, legs[i], &outcomes[i]); err != nil {
+			return candidates, nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return candidates, nil, fmt.Errorf("commit: %w", err)
+	}
+	return candidates, outcomes[:attempted], nil
+}
+
+// attemptCandidate cancels candidate under a savepoint and queues a retry when
+// it is skipped or fails; legs are nil when they could not be loaded. It
+// returns an error only when the batch transaction is lost.
+func (s *Scheduler) attemptCandidate(ctx context.Context, tx *sql.Tx, candidate CandidateOperation, legs []*domain.Operation, outcome *batchOutcome) error {
+	ctx, span := tracer.Start(ctx, "cancel_scheduler.cancel", trace.WithAttributes(
+		attribute.Int64("op_id", candidate.ID),
+		attribute.String("tx_id", candidate.TxID)))
+	defer span.End()
+
+	if legs != nil {
+		failure, err := withSavepoint(ctx, tx, func() error {
+			var err error
+			outcome.result, err = s.cancelCandidate(ctx, tx, legs)
+			return err
+		})
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return err
+		}
+		if failure != nil {
+			s.log.Error("failed to cancel operation", zap.Int64("op_id", candidate.ID), zap.Error(failure))
+			span.RecordError(failure)
+			span.SetStatus(codes.Error, failure.Error())
+			outcome.result = CancelResultFailed
+		}
+	}
+	span.SetAttributes(attribute.String("result", outcome.result.String()))
+
+	var reason RetryReason
+	switch outcome.result {
+	case CancelResultSkipped:
+		reason = RetryReasonInsufficientFunds
+	case CancelResultFailed:
+		reason = RetryReasonFailed
+	default:
+		return nil
+	}
+
+	var err error
+	if outcome.parked, err = s.recordAttempt(ctx, tx, candidate, reason); err != nil {
+		return fmt.Errorf("schedule retry (op %d): %w", candidate.ID, err)
+	}
+	span.SetAttributes(attribute.Bool("parked", outcome.parked))
+	return nil
+}
+
+// withSavepoint runs fn under a savepoint. When fn fails its writes are rolled
+// back, the transaction stays usable and the failure is returned; err is set
+// only when the transaction itself is lost.
+func withSavepoint(ctx context.Context, tx *sql.Tx, fn func() error) (failure, err error) {
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT candidate"); err != nil {
+		return nil, fmt.Errorf("create savepoint: %w", err)
+	}
+
+	if failure = fn(); failure != nil {
+		if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT candidate"); err != nil {
+			return nil, fmt.Errorf("rollback to savepoint: %w", err)
+		}
+		return failure, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT candidate"); err != nil {
+		return nil, fmt.Errorf("release savepoint: %w", err)
+	}
+	return nil, nil
+}
+
+// tally adds an outcome to the run and records it.
+func (s *Scheduler) tally(history context.Context, c *cycleRun, o batchOutcome) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	run := c.run
+	outcome := domain.SchedulerRunOutcome{OperationID: o.candidate.ID, TxID: o.candidate.TxID, Parked: o.parked}
+	run.Candidates++
+
+	switch o.result {
+	case CancelResultSuccess:
+		outcome.Result = domain.CancelOutcomeCancelled
+		run.Cancelled++
+		s.log.Info("operation cancelled successfully",
+			zap.Int64("op_id", o.candidate.ID),
+			zap.String("tx_id", o.candidate.TxID))
+	case CancelResultSkipped:
+		outcome.Result = domain.CancelOutcomeSkipped
+		run.Skipped++
+		s.log.Info("operation skipped due to insufficient funds",
+			zap.Int64("op_id", o.candidate.ID),
+			zap.String("tx_id", o.candidate.TxID))
+	case CancelResultFailed:
+		outcome.Result = domain.CancelOutcomeFailed
+		run.Failed++
+		s.log.Warn("operation cancellation failed",
+			zap.Int64("op_id", o.candidate.ID),
+			zap.String("tx_id", o.candidate.TxID))
+	case CancelResultNotApplicable:
+		outcome.Result = domain.CancelOutcomeNotApplicable
+		run.NotApplicable++
+		s.log.Debug("operation no longer needs cancelling",
+			zap.Int64("op_id", o.candidate.ID),
+			zap.String("tx_id", o.candidate.TxID))
+	}
+	if outcome.Parked {
+		run.Parked++
+		s.log.Warn("cancellation parked for manual review",
+			zap.Int64("op_id", o.candidate.ID),
+			zap.String("tx_id", o.candidate.TxID))
+	}
+
+	if err := s.recordOutcome(history, run.ID, &outcome); err != nil {
+		s.log.Error("failed to record cancellation outcome",
+			zap.Int64("run_id", run.ID),
+			zap.Int64("op_id", o.candidate.ID),
+			zap.Error(err))
+	}
+	run.Outcomes = append(run.Outcomes, outcome)
+}
@@ -0,0 +1,359 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/db"
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/domain"
+	"go.uber.org/zap"
+)
+
+// recordingConn is a database connection that records the statements it
+// runs and fails those starting with a prefix in fail. Queries return the
+// row of the first prefix in rows they start with, or no rows. Statements
+// starting with a prefix in conflict affect no rows. With connectErr set the
+// database is unreachable.
+type recordingConn struct {
+	connectErr error
+
+	mu         sync.Mutex
+	statements []string
+	fail       map[string]error
+	conflict   []string
+	rows       map[string][]driver.Value
+}
+
+func newRecordingDB(conn *recordingConn) *db.DB {
+	return &db.DB{DB: sql.OpenDB(conn)}
+}
+
+func (c *recordingConn) Connect(context.Context) (driver.Conn, error) {
+	if c.connectErr != nil {
+		return nil, c.connectErr
+	}
+	return c, nil
+}
+func (c *recordingConn) Driver() driver.Driver { return nil }
+
+func (c *recordingConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+func (c *recordingConn) Close() error              { return nil }
+func (c *recordingConn) Begin() (driver.Tx, error) { return c, c.run("BEGIN") }
+func (c *recordingConn) Commit() error             { return c.run("COMMIT") }
+func (c *recordingConn) Rollback() error           { return c.run("ROLLBACK") }
+
+func (c *recordingConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	if err := c.run(query); err != nil {
+		return nil, err
+	}
+	for _, prefix := range c.conflict {
+		if strings.HasPrefix(strings.TrimSpace(query), prefix) {
+			return driver.RowsAffected(0), nil
+		}
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *recordingConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	if err := c.run(query); err != nil {
+		return nil, err
+	}
+	for prefix, row := range c.rows {
+		if strings.HasPrefix(strings.Join(strings.Fields(query), " "), prefix) {
+			return &oneRow{values: row}, nil
+		}
+	}
+	return noRows{}, nil
+}
+
+func (c *recordingConn) run(query string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	query = strings.Join(strings.Fields(query), " ")
+	c.statements = append(c.statements, query)
+	for prefix, err := range c.fail {
+		if strings.HasPrefix(query, prefix) {
+			return err
+		}
+	}
+	return nil
+}
+
+// executed returns the recorded statements starting with prefix.
+func (c *recordingConn) executed(prefix string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var matched []string
+	for _, statement := range c.statements {
+		if strings.HasPrefix(statement, prefix) {
+			matched = append(matched, statement)
+		}
+	}
+	return matched
+}
+
+type noRows struct{}
+
+func (noRows) Columns() []string              { return nil }
+func (noRows) Close() error                   { return nil }
+func (noRows) Next(dest []driver.Value) error { return io.EOF }
+
+type oneRow struct {
+	values []driver.Value
+	done   bool
+}
+
+func (r *oneRow) Columns() []string { return make([]string, len(r.values)) }
+func (r *oneRow) Close() error      { return nil }
+
+func (r *oneRow) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	copy(dest, r.values)
+	return nil
+}
+
+func beginRecorded(t *testing.T, conn *recordingConn) *sql.Tx {
+	t.Helper()
+	tx, err := newRecordingDB(conn).BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("BeginTx() error = %v", err)
+	}
+	t.Cleanup(func() { _ = tx.Rollback() })
+	return tx
+}
+
+func TestWithSavepoint(t *testing.T) {
+	lost := errors.New("connection lost")
+	failure := errors.New("guard rejected")
+
+	tests := []struct {
+		name        string
+		fail        map[string]error
+		fn          error
+		wantFailure error
+		wantErr     bool
+		statements  []string
+	}{
+		{
+			name:       "fn succeeds",
+			statements: []string{"SAVEPOINT candidate", "RELEASE SAVEPOINT candidate"},
+		},
+		{
+			name:        "fn fails",
+			fn:          failure,
+			wantFailure: failure,
+			statements:  []string{"SAVEPOINT candidate", "ROLLBACK TO SAVEPOINT candidate"},
+		},
+		{
+			name:       "savepoint fails",
+			fail:       map[string]error{"SAVEPOINT": lost},
+			wantErr:    true,
+			statements: []string{"SAVEPOINT candidate"},
+		},
+		{
+			name:       "rollback to savepoint fails",
+			fail:       map[string]error{"ROLLBACK TO": lost},
+			fn:         failure,
+			wantErr:    true,
+			statements: []string{"SAVEPOINT candidate", "ROLLBACK TO SAVEPOINT candidate"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &recordingConn{}
+			tx := beginRecorded(t, conn)
+			conn.fail = tt.fail
+
+			called := false
+			gotFailure, err := withSavepoint(context.Background(), tx, func() error {
+				called = true
+				return tt.fn
+			})
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("withSavepoint() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !errors.Is(gotFailure, tt.wantFailure) || (gotFailure == nil) != (tt.wantFailure == nil) {
+				t.Errorf("withSavepoint() failure = %v, want %v", gotFailure, tt.wantFailure)
+			}
+			// fn never runs without its savepoint
+			if wantCalled := len(tt.statements) > 1; called != wantCalled {
+				t.Errorf("fn called = %v, want %v", called, wantCalled)
+			}
+			if got := conn.statements[1:]; !slices.Equal(got, tt.statements) {
+				t.Errorf("statements = %q, want %q", got, tt.statements)
+			}
+		})
+	}
+}
+
+func TestCycleRun_Take(t *testing.T) {
+	c := newCycleRun(&domain.SchedulerRun{}, time.Time{}, []CandidateOperation{{ID: 1}, {ID: 2}, {ID: 3}})
+
+	if got := c.take(2); len(got) != 2 || got[0].ID != 1 || got[1].ID != 2 {
+		t.Errorf("take(2) = %v, want operations 1 and 2", got)
+	}
+	if got := c.take(2); len(got) != 1 || got[0].ID != 3 {
+		t.Errorf("take(2) = %v, want operation 3", got)
+	}
+	if got := c.take(2); len(got) != 0 {
+		t.Errorf("take(2) = %v with nothing left, want none", got)
+	}
+}
+
+// scriptedBatches returns a batchFunc that runs batches in order, then empty ones.
+func scriptedBatches(batches ...func(c *cycleRun) ([]CandidateOperation, []batchOutcome, error)) (batchFunc, *int) {
+	calls := 0
+	return func(_ context.Context, c *cycleRun) ([]CandidateOperation, []batchOutcome, error) {
+		calls++
+		if calls > len(batches) {
+			return nil, nil, nil
+		}
+		return batches[calls-1](c)
+	}, &calls
+}
+
+func attempted(outcomes ...batchOutcome) func(*cycleRun) ([]CandidateOperation, []batchOutcome, error) {
+	return func(*cycleRun) ([]CandidateOperation, []batchOutcome, error) {
+		claimed := make([]CandidateOperation, len(outcomes))
+		for i, outcome := range outcomes {
+			claimed[i] = outcome.candidate
+		}
+		return claimed, outcomes, nil
+	}
+}
+
+func newDrainScheduler(conn *recordingConn, clock Clock) *Scheduler {
+	return &Scheduler{
+		db:    newRecordingDB(conn),
+		clock: clock,
+		retry: RetryPolicy{MaxAttempts: 5, BaseDelay: time.Minute, MaxDelay: time.Hour},
+		log:   zap.NewNop(),
+	}
+}
+
+func TestDrain_UntilNoCandidatesLeft(t *testing.T) {
+	conn := &recordingConn{}
+	s := newDrainScheduler(conn, &fakeClock{now: time.Now()})
+	c := newCycleRun(&domain.SchedulerRun{ID: 1}, time.Time{}, nil)
+
+	batch, calls := scriptedBatches(
+		attempted(
+			batchOutcome{candidate: CandidateOperation{ID: 1, TxID: "tx-1"}, result: CancelResultSuccess},
+			batchOutcome{candidate: CandidateOperation{ID: 2, TxID: "tx-2"}, result: CancelResultSkipped, parked: true},
+		),
+		attempted(
+			batchOutcome{candidate: CandidateOperation{ID: 3, TxID: "tx-3"}, result: CancelResultNotApplicable},
+		),
+	)
+	s.drain(context.Background(), context.Background(), c, batch)
+
+	// the third batch finds nothing and ends the drain
+	if *calls != 3 {
+		t.Errorf("batches = %d, want 3", *calls)
+	}
+	run := c.run
+	if run.Candidates != 3 || run.Cancelled != 1 || run.Skipped != 1 || run.NotApplicable != 1 || run.Parked != 1 {
+		t.Errorf("run = %+v, want 3 candidates: 1 cancelled, 1 skipped (parked), 1 not applicable", run)
+	}
+	if got := len(conn.executed("INSERT INTO scheduler_run_outcomes")); got != 3 {
+		t.Errorf("recorded outcomes = %d, want 3", got)
+	}
+	if c.overBudget {
+		t.Error("overBudget = true without a budget")
+	}
+}
+
+func TestDrain_StopsWhenBudgetUsedUp(t *testing.T) {
+	start := time.Date(2025, 2, 1, 10, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: start}
+	s := newDrainScheduler(&recordingConn{}, clock)
+	c := newCycleRun(&domain.SchedulerRun{ID: 1}, start.Add(time.Minute), nil)
+
+	first := attempted(batchOutcome{candidate: CandidateOperation{ID: 1}, result: CancelResultSuccess})
+	batch, calls := scriptedBatches(
+		func(c *cycleRun) ([]CandidateOperation, []batchOutcome, error) {
+			clock.now = start.Add(2 * time.Minute)
+			return first(c)
+		},
+		attempted(batchOutcome{candidate: CandidateOperation{ID: 2}, result: CancelResultSuccess}),
+	)
+	s.drain(context.Background(), context.Background(), c, batch)
+
+	if *calls != 1 {
+		t.Errorf("batches = %d, want 1 before the budget ran out", *calls)
+	}
+	if !c.overBudget {
+		t.Error("overBudget = false after the deadline")
+	}
+	if c.run.Cancelled != 1 {
+		t.Errorf("cancelled = %d, want the first batch's 1", c.run.Cancelled)
+	}
+}
+
+func TestDrain_FailedBatchQueuesRetries(t *testing.T) {
+	conn := &recordingConn{}
+	s := newDrainScheduler(conn, &fakeClock{now: time.Now()})
+	c := newCycleRun(&domain.SchedulerRun{ID: 1}, time.Time{}, nil)
+
+	claimed := []CandidateOperation{{ID: 1, TxID: "tx-1"}, {ID: 2, TxID: "tx-2"}}
+	batch, calls := scriptedBatches(
+		func(*cycleRun) ([]CandidateOperation, []batchOutcome, error) {
+			// outcomes of a batch that did not commit count for nothing
+			return claimed, []batchOutcome{{candidate: claimed[0], result: CancelResultSuccess}}, errors.New("commit: connection lost")
+		},
+		attempted(batchOutcome{candidate: CandidateOperation{ID: 3}, result: CancelResultSuccess}),
+	)
+	s.drain(context.Background(), context.Background(), c, batch)
+
+	// the worker stops; the candidates wait for their retry
+	if *calls != 1 {
+		t.Errorf("batches = %d, want 1", *calls)
+	}
+	if c.run.Candidates != 2 || c.run.Failed != 2 || c.run.Cancelled != 0 {
+		t.Errorf("run = %+v, want both claimed candidates failed", c.run)
+	}
+	if got := len(conn.executed("INSERT INTO cancel_retries")); got != 2 {
+		t.Errorf("queued retries = %d, want 2", got)
+	}
+}
+
+func TestDrain_StopsWhenCycleStopped(t *testing.T) {
+	s := newDrainScheduler(&recordingConn{}, &fakeClock{now: time.Now()})
+	c := newCycleRun(&domain.SchedulerRun{ID: 1}, time.Time{}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := attempted(batchOutcome{candidate: CandidateOperation{ID: 1}, result: CancelResultSuccess})
+	batch, calls := scriptedBatches(
+		func(c *cycleRun) ([]CandidateOperation, []batchOutcome, error) {
+			cancel()
+			return first(c)
+		},
+		attempted(batchOutcome{candidate: CandidateOperation{ID: 2}, result: CancelResultSuccess}),
+	)
+	s.drain(ctx, context.Background(), c, batch)
+
+	if *calls != 1 {
+		t.Errorf("batches = %d, want 1 after the cycle was stopped", *calls)
+	}
+	// history is written even though the cycle was stopped
+	if c.run.Cancelled != 1 {
+		t.Errorf("cancelled = %d, want 1", c.run.Cancelled)
+	}
+}
@@ -4,9 +4,14 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/domain"
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/events"
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/ledger"
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
@@ -18,13 +23,73 @@ const (
 	CancelResultSuccess CancelResult = iota
 	CancelResultSkipped
 	CancelResultFailed
+	// CancelResultNotApplicable means the operation was already canceled or never applied.
+	CancelResultNotApplicable
 )
 
-func (s *Scheduler) cancelOne(ctx context.Context, operationID int64) CancelResult {
+func (r CancelResult) String() string {
+	switch r {
+	case CancelResultSuccess:
+		return string(domain.CancelOutcomeCancelled)
+	case CancelResultSkipped:
+		return string(domain.CancelOutcomeSkipped)
+	case CancelResultFailed:
+		return string(domain.CancelOutcomeFailed)
+	case CancelResultNotApplicable:
+		return string(domain.CancelOutcomeNotApplicable)
+	default:
+		return "unknown"
+	}
+}
+
+// cancelNotes are written into cancel_note by a successful cancellation.
+type cancelNotes struct {
+	original     string
+	compensation string
+}
+
+var schedulerCancelNotes = cancelNotes{original: "scheduler", compensation: "auto-cancel"}
+
+const manualCancelNote = "manual-cancel"
+
+// cancelCandidate cancels one candidate inside a batch transaction. Errors
+// leave the transaction for the caller to roll back to its savepoint.
+func (s *Scheduler) cancelCandidate(ctx context.Context, tx *sql.Tx, legs []*domain.Operation) (CancelResult, error) {
+	// idempotency: skip if not applied or already canceled
+	for _, leg := range legs {
+		if !leg.Applied || leg.CanceledAt != nil {
+			s.log.Debug("operation already cancelled or not applied", zap.Int64("op_id", leg.ID))
+			if err := s.clearRetries(ctx, tx, legs); err != nil {
+				return CancelResultFailed, fmt.Errorf("clear cancellation retries: %w", err)
+			}
+			return CancelResultNotApplicable, nil
+		}
+	}
+
+	compensated, err := s.compensate(ctx, tx, legs, schedulerCancelNotes)
+	if err != nil {
+		return CancelResultFailed, fmt.Errorf("compensate: %w", err)
+	}
+	if compensated {
+		return CancelResultSuccess, nil
+	}
+
+	for _, leg := range legs {
+		if err := s.markOperationAsSkipped(ctx, tx, leg.ID); err != nil {
+			return CancelResultFailed, fmt.Errorf("mark operation as skipped (op %d): %w", leg.ID, err)
+		}
+	}
+	return CancelResultSkipped, nil
+}
+
+// CancelOperation cancels the operation with txID on behalf of a caller, the
+// same way the scheduler does, and stores reason in its cancel_note. It reports
+// true if the operation had already been canceled. Unlike the scheduler it does
+// not record a skip when funds are short, it returns ErrInsufficientFundsToCancel.
+func (s *Scheduler) CancelOperation(ctx context.Context, txID string, reason string) (bool, error) {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		s.log.Error("failed to begin transaction for cancellation", zap.Int64("op_id", operationID), zap.Error(err))
-		return CancelResultFailed
+		return false, fmt.Errorf("begin: %w", err)
 	}
 	defer func() {
 		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
@@ -32,74 +97,150 @@ func (s *Scheduler) cancelOne(ctx context.Context, operationID int64) CancelResu
 		}
 	}()
 
-	// load operation
-	operation, err := s.loadOperation(ctx, tx, operationID)
+	// the tx_id of a transfer resolves to its debit leg
+	var operationID int64
+	if err := tx.QueryRowContext(ctx, `
+		SELECT id FROM operations
+		WHERE tx_id IN ($1, $2)
+		ORDER BY tx_id = $1 DESC
+		LIMIT 1`, txID, domain.TransferDebitTxID(txID)).Scan(&operationID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, domain.ErrOperationNotFound
+		}
+		return false, fmt.Errorf("find operation: %w", err)
+	}
+
+	operation, err := s.loadOperation(ctx, tx, operationID, false)
 	if err != nil {
-		s.log.Error("failed to load operation for cancellation", zap.Int64("op_id", operationID), zap.Error(err))
-		return CancelResultFailed
+		return false, fmt.Errorf("load operation: %w", err)
+	}
+	if !cancellable(operation) {
+		return false, domain.ErrOperationNotCancellable
 	}
 
-	// idempotency: skip if not applied or already canceled
-	if !operation.Applied || operation.CanceledAt != nil {
-		s.log.Debug("operation already cancelled or not applied", zap.Int64("op_id", operationID))
-		if commitErr := tx.Commit(); commitErr != nil {
-			s.log.Error("failed to commit transaction", zap.Error(commitErr))
+	legs, err := s.lockLegs(ctx, tx, operation)
+	if err != nil {
+		return false, fmt.Errorf("lock operation legs: %w", err)
+	}
+
+	// idempotency: a canceled operation stays canceled
+	for _, leg := range legs {
+		if leg.CanceledAt != nil {
+			return true, nil
 		}
-		return CancelResultSkipped
 	}
 
-	// compute delta
-	compensatingDelta := s.calculateCompensatingDelta(operation.State, operation.Amount)
+	compensated, err := s.compensate(ctx, tx, legs, cancelNotes{original: reason, compensation: manualCancelNote})
+	if err != nil {
+		return false, err
+	}
+	if !compensated {
+		return false, domain.ErrInsufficientFundsToCancel
+	}
 
-	// apply delta with non-negative guard
-	balanceUpdated, err := s.updateAccountBalance(ctx, tx, operation.AccountID, compensatingDelta)
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("commit: %w", err)
+	}
+
+	s.log.Info("operation cancelled manually", zap.String("tx_id", txID), zap.String("reason", reason))
+	return false, nil
+}
+
+// cancellable reports whether op is a deposit or withdraw that moved money
+// and is not itself a compensation.
+func cancellable(op *domain.Operation) bool {
+	if !op.Applied || strings.HasPrefix(op.TxID, domain.CompensatingTxIDPrefix) {
+		return false
+	}
+	return op.State == domain.StateDeposit || op.State == domain.StateWithdraw
+}
+
+// lockLegs locks the operations canceled together with operation: both legs
+// of a transfer, otherwise the operation alone. Reads after the lock see a
+// concurrent cancellation.
+func (s *Scheduler) lockLegs(ctx context.Context, tx *sql.Tx, operation *domain.Operation) ([]*domain.Operation, error) {
+	return s.loadLegs(ctx, tx, operation, true)
+}
+
+func (s *Scheduler) loadLegs(ctx context.Context, tx *sql.Tx, operation *domain.Operation, forUpdate bool) ([]*domain.Operation, error) {
+	// a transfer is canceled as a whole: both legs or none
+	if operation.TransferID != nil {
+		return s.loadTransferLegs(ctx, tx, *operation.TransferID, forUpdate)
+	}
+
+	op, err := s.loadOperation(ctx, tx, operation.ID, forUpdate)
 	if err != nil {
-		s.log.Error("failed to update account balance", zap.Int64("op_id", operationID), zap.Error(err))
-		return CancelResultFailed
+		return nil, err
 	}
+	return []*domain.Operation{op}, nil
+}
 
-	// mark skipped and commit
-	if !balanceUpdated {
-		if err := s.markOperationAsSkipped(ctx, tx, operationID); err != nil {
-			s.log.Error("failed to mark operation as skipped", zap.Int64("op_id", operationID), zap.Error(err))
-			return CancelResultFailed
-		}
+// compensate reverses legs: all of them, or none when an account lacks the
+// funds, in which case it returns false and leaves the transaction usable.
+func (s *Scheduler) compensate(ctx context.Context, tx *sql.Tx, legs []*domain.Operation, notes cancelNotes) (bool, error) {
+	if err := s.lockAccounts(ctx, tx, legs); err != nil {
+		return false, fmt.Errorf("lock accounts: %w", err)
+	}
 
-		if err := tx.Commit(); err != nil {
-			s.log.Error("failed to commit skip transaction", zap.Error(err))
-			return CancelResultFailed
+	// apply deltas with non-negative guard, all of them or none
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT compensate"); err != nil {
+		return false, fmt.Errorf("create savepoint: %w", err)
+	}
+
+	accounts, applied, err := s.applyCompensatingDeltas(ctx, legs, func(ctx context.Context, accountID uuid.UUID, delta decimal.Decimal) (*domain.Account, error) {
+		return s.updateAccountBalance(ctx, tx, accountID, delta)
+	})
+	if err != nil {
+		return false, err
+	}
+	if !applied {
+		if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT compensate"); err != nil {
+			return false, fmt.Errorf("rollback to savepoint: %w", err)
 		}
-		return CancelResultSkipped
+		return false, nil
 	}
 
-	compensatingTxID := "cancel::" + operation.TxID
+	for i, leg := range legs {
+		compensatingTxID := domain.CompensatingTxID(leg.TxID)
+		compensatingDelta := s.calculateCompensatingDelta(leg.State, leg.Amount)
 
-	// write compensating operation
-	if err := s.createCompensatingOperation(ctx, tx, operation, compensatingTxID, compensatingDelta); err != nil {
-		s.log.Error("failed to create compensating operation", zap.Int64("op_id", operationID), zap.Error(err))
-		return CancelResultFailed
-	}
+		// write compensating operation
+		if err := s.createCompensatingOperation(ctx, tx, leg, compensatingTxID, compensatingDelta, notes.compensation); err != nil {
+			return false, fmt.Errorf("create compensating operation (op %d): %w", leg.ID, err)
+		}
 
-	// / mark original as canceled
-	if err := s.markOperationAsCancelled(ctx, tx, operationID); err != nil {
-		s.log.Error("failed to mark operation as cancelled", zap.Int64("op_id", operationID), zap.Error(err))
-		return CancelResultFailed
+		// reverse against the source the money came from, not the service source of the compensation
+		if err := ledger.Post(ctx, tx, compensatingTxID, leg.AccountID, leg.Source, compensatingDelta); err != nil {
+			return false, fmt.Errorf("post compensating ledger entries (op %d): %w", leg.ID, err)
+		}
+
+		// mark original as canceled
+		if err := s.markOperationAsCancelled(ctx, tx, leg.ID, notes.original); err != nil {
+			return false, fmt.Errorf("mark operation as cancelled (op %d): %w", leg.ID, err)
+		}
+
+		// watchers see the change with the tx_id that caused it
+		if err := events.PublishBalance(ctx, tx, domain.NewBalanceEvent(accounts[i], compensatingTxID)); err != nil {
+			return false, fmt.Errorf("publish balance event (op %d): %w", leg.ID, err)
+		}
 	}
 
-	// commit
-	if err := tx.Commit(); err != nil {
-		s.log.Error("failed to commit cancellation transaction", zap.Error(err))
-		return CancelResultFailed
+	// a manual cancel also settles a pending or parked retry
+	if err := s.clearRetries(ctx, tx, legs); err != nil {
+		return false, fmt.Errorf("clear cancellation retries: %w", err)
 	}
 
-	return CancelResultSuccess
+	return true, nil
 }
 
-func (s *Scheduler) loadOperation(ctx context.Context, tx *sql.Tx, operationID int64) (*domain.Operation, error) {
+func (s *Scheduler) loadOperation(ctx context.Context, tx *sql.Tx, operationID int64, forUpdate bool) (*domain.Operation, error) {
 	query := `
-		SELECT id, tx_id, account_id, source, state, amount, created_at, applied, canceled_at, cancel_note
+		SELECT id, tx_id, account_id, source, state, amount, created_at, applied, canceled_at, cancel_note, transfer_id
 		FROM operations
 		WHERE id = $1`
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
 
 	var op domain.Operation
 	var canceledAt *time.Time
@@ -107,7 +248,7 @@ func (s *Scheduler) loadOperation(ctx context.Context, tx *sql.Tx, operationID i
 
 	err := tx.QueryRowContext(ctx, query, operationID).Scan(
 		&op.ID, &op.TxID, &op.AccountID, &op.Source, &op.State, &op.Amount,
-		&op.CreatedAt, &op.Applied, &canceledAt, &cancelNote,
+		&op.CreatedAt, &op.Applied, &canceledAt, &cancelNote, &op.TransferID,
 	)
 	if err != nil {
 		return nil, err
@@ -118,6 +259,93 @@ func (s *Scheduler) loadOperation(ctx context.Context, tx *sql.Tx, operationID i
 	return &op, nil
 }
 
+func (s *Scheduler) loadTransferLegs(ctx context.Context, tx *sql.Tx, transferID string, forUpdate bool) ([]*domain.Operation, error) {
+	query := `
+		SELECT id, tx_id, account_id, source, state, amount, created_at, applied, canceled_at, cancel_note, transfer_id
+		FROM operations
+		WHERE transfer_id = $1
+		ORDER BY id`
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	rows, err := tx.QueryContext(ctx, query, transferID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var legs []*domain.Operation
+	for rows.Next() {
+		var op domain.Operation
+		if err := rows.Scan(
+			&op.ID, &op.TxID, &op.AccountID, &op.Source, &op.State, &op.Amount,
+			&op.CreatedAt, &op.Applied, &op.CanceledAt, &op.CancelNote, &op.TransferID,
+		); err != nil {
+			return nil, err
+		}
+		legs = append(legs, &op)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(legs) != 2 {
+		return nil, fmt.Errorf("transfer %s has %d legs, want 2", transferID, len(legs))
+	}
+	return legs, nil
+}
+
+// lockAccounts locks the accounts touched by legs in a deterministic order.
+func (s *Scheduler) lockAccounts(ctx context.Context, tx *sql.Tx, legs []*domain.Operation) error {
+	ids := make([]uuid.UUID, 0, len(legs))
+	for _, leg := range legs {
+		ids = append(ids, leg.AccountID)
+	}
+
+	for _, id := range domain.AccountLockOrder(ids...) {
+		if _, err := tx.ExecContext(ctx, `SELECT id FROM accounts WHERE id = $1 FOR UPDATE`, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// balanceUpdate adds delta to the balance of an account and returns the
+// account, or nil when the non-negative guard rejects the delta.
+type balanceUpdate func(ctx context.Context, accountID uuid.UUID, delta decimal.Decimal) (*domain.Account, error)
+
+// applyCompensatingDeltas applies the compensating delta of every leg with
+// update, the debits first. It stops at the first delta the guard rejects and
+// returns false; undoing the deltas already applied is up to the caller. The
+// dry run takes the same path with balances kept in memory.
+func (s *Scheduler) applyCompensatingDeltas(ctx context.Context, legs []*domain.Operation, update balanceUpdate) ([]*domain.Account, bool, error) {
+	s.sortByCompensatingDelta(legs)
+
+	accounts := make([]*domain.Account, len(legs))
+	for i, leg := range legs {
+		acc, err := update(ctx, leg.AccountID, s.calculateCompensatingDelta(leg.State, leg.Amount))
+		if err != nil {
+			return nil, false, fmt.Errorf("update account balance (op %d): %w", leg.ID, err)
+		}
+		if acc == nil {
+			return nil, false, nil
+		}
+		accounts[i] = acc
+	}
+	return accounts, true, nil
+}
+
+// sortByCompensatingDelta puts legs whose compensation debits an account first:
+// only those can hit the non-negative guard, so nothing is applied before they pass.
+func (s *Scheduler) sortByCompensatingDelta(legs []*domain.Operation) {
+	sort.SliceStable(legs, func(i, j int) bool {
+		di := s.calculateCompensatingDelta(legs[i].State, legs[i].Amount)
+		dj := s.calculateCompensatingDelta(legs[j].State, legs[j].Amount)
+		return di.LessThan(dj)
+	})
+}
+
 func (s *Scheduler) calculateCompensatingDelta(state domain.State, amount decimal.Decimal) decimal.Decimal {
 	switch state {
 	case domain.StateDeposit:
@@ -129,42 +357,55 @@ func (s *Scheduler) calculateCompensatingDelta(state domain.State, amount decima
 	}
 }
 
-func (s *Scheduler) updateAccountBalance(ctx context.Context, tx *sql.Tx, accountID uuid.UUID, delta decimal.Decimal) (bool, error) {
+// updateAccountBalance returns the updated account, or nil if the guard rejected the delta.
+func (s *Scheduler) updateAccountBalance(ctx context.Context, tx *sql.Tx, accountID uuid.UUID, delta decimal.Decimal) (*domain.Account, error) {
 	query := `
 		UPDATE accounts
 		SET balance = balance + $1, updated_at = now()
-		WHERE id = $2 AND balance + $1 >= 0
-		RETURNING balance, updated_at`
+		WHERE id = $2 AND balance - held + $1 >= 0
+		RETURNING balance, held, updated_at`
 
-	var balance decimal.Decimal
-	var updatedAt time.Time
-	err := tx.QueryRowContext(ctx, query, delta, accountID).Scan(&balance, &updatedAt)
+	acc := domain.Account{ID: accountID}
+	err := tx.QueryRowContext(ctx, query, delta, accountID).Scan(&acc.Balance, &acc.Held, &acc.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return false, nil
+			return nil, nil
 		}
-		return false, err
+		return nil, err
 	}
 
-	return true, nil
+	return &acc, nil
 }
 
-func (s *Scheduler) createCompensatingOperation(ctx context.Context, tx *sql.Tx, originalOp *domain.Operation, compensatingTxID string, amount decimal.Decimal) error {
+func (s *Scheduler) createCompensatingOperation(ctx context.Context, tx *sql.Tx, originalOp *domain.Operation, compensatingTxID string, amount decimal.Decimal, note string) error {
 	compensatingState := s.getCompensatingState(originalOp.State)
 
 	query := `
 		INSERT INTO operations (tx_id, account_id, source, state, amount, applied, cancel_note)
-		VALUES ($1, $2, $3, $4, $5, TRUE, 'auto-cancel')
+		VALUES ($1, $2, $3, $4, $5, TRUE, $6)
 		ON CONFLICT (tx_id) DO NOTHING`
 
-	_, err := tx.ExecContext(ctx, query,
+	result, err := tx.ExecContext(ctx, query,
 		compensatingTxID,
 		originalOp.AccountID,
 		domain.SourceService,
 		compensatingState,
 		amount.Abs(),
+		note,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+
+	// the ledger entries and GetOperation rely on the row being this compensation
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if inserted != 1 {
+		return fmt.Errorf("tx_id %s is already taken by another operation", compensatingTxID)
+	}
+	return nil
 }
 
 func (s *Scheduler) getCompensatingState(originalState domain.State) domain.State {
@@ -178,13 +419,13 @@ func (s *Scheduler) getCompensatingState(originalState domain.State) domain.Stat
 	}
 }
 
-func (s *Scheduler) markOperationAsCancelled(ctx context.Context, tx *sql.Tx, operationID int64) error {
+func (s *Scheduler) markOperationAsCancelled(ctx context.Context, tx *sql.Tx, operationID int64, note string) error {
 	query := `
 		UPDATE operations
-		SET canceled_at = now(), cancel_note = 'scheduler'
+		SET canceled_at = now(), cancel_note = $2
 		WHERE id = $1`
 
-	_, err := tx.ExecContext(ctx, query, operationID)
+	_, err := tx.ExecContext(ctx, query, operationID, note)
 	return err
 }
 
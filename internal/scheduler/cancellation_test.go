@@ -1,10 +1,14 @@
 package scheduler
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/domain"
 	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
 )
 
 func TestCalculateCompensatingDelta(t *testing.T) {
@@ -47,3 +51,350 @@ func TestGetCompensatingState(t *testing.T) {
 		}
 	}
 }
+
+func TestSortByCompensatingDelta(t *testing.T) {
+	s := &Scheduler{}
+
+	debit := &domain.Operation{ID: 1, State: domain.StateWithdraw, Amount: decimal.NewFromFloat(10)}
+	credit := &domain.Operation{ID: 2, State: domain.StateDeposit, Amount: decimal.NewFromFloat(10)}
+
+	legs := []*domain.Operation{debit, credit}
+	s.sortByCompensatingDelta(legs)
+
+	if legs[0] != credit || legs[1] != debit {
+		t.Errorf("sortByCompensatingDelta() = [%d %d], want [2 1]", legs[0].ID, legs[1].ID)
+	}
+}
+
+func TestCancellable(t *testing.T) {
+	tests := []struct {
+		op       domain.Operation
+		expected bool
+	}{
+		{domain.Operation{TxID: "tx-1", State: domain.StateDeposit, Applied: true}, true},
+		{domain.Operation{TxID: "tx-2", State: domain.StateWithdraw, Applied: true}, true},
+		{domain.Operation{TxID: "tx-3", State: domain.StateDeposit, Applied: false}, false},
+		{domain.Operation{TxID: "tx-4", State: domain.StateHold, Applied: true}, false},
+		{domain.Operation{TxID: domain.CompensatingTxID("tx-1"), State: domain.StateWithdraw, Applied: true}, false},
+	}
+
+	for _, tt := range tests {
+		if result := cancellable(&tt.op); result != tt.expected {
+			t.Errorf("cancellable(%v %v applied=%v) = %v, want %v",
+				tt.op.TxID, tt.op.State, tt.op.Applied, result, tt.expected)
+		}
+	}
+}
+
+type fakeClock struct {
+	now   time.Time
+	waits chan time.Duration
+	fire  chan time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.waits <- d
+	return c.fire
+}
+
+type fakeLeader struct{}
+
+func (fakeLeader) ID() string     { return "test" }
+func (fakeLeader) IsLeader() bool { return false }
+
+func (fakeLeader) Leadership(ctx context.Context) (context.Context, context.CancelFunc, bool) {
+	return ctx, func() {}, false
+}
+
+func mustTimetable(t *testing.T, cfg TimetableConfig) *Timetable {
+	t.Helper()
+	tt, err := NewTimetable(cfg)
+	if err != nil {
+		t.Fatalf("NewTimetable() error = %v", err)
+	}
+	return tt
+}
+
+func TestTimetable_Next(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      TimetableConfig
+		now      time.Time
+		expected time.Time
+	}{
+		{
+			name:     "period",
+			cfg:      TimetableConfig{Period: 5 * time.Minute},
+			now:      time.Date(2025, 2, 1, 10, 2, 0, 0, time.UTC),
+			expected: time.Date(2025, 2, 1, 10, 7, 0, 0, time.UTC),
+		},
+		{
+			name:     "cron",
+			cfg:      TimetableConfig{Cron: "*/15 * * * *"},
+			now:      time.Date(2025, 2, 1, 10, 2, 0, 0, time.UTC),
+			expected: time.Date(2025, 2, 1, 10, 15, 0, 0, time.UTC),
+		},
+		{
+			name:     "period resumes when the blackout ends",
+			cfg:      TimetableConfig{Period: 5 * time.Minute, Blackouts: []string{"23:00-01:00"}},
+			now:      time.Date(2025, 2, 1, 22, 58, 0, 0, time.UTC),
+			expected: time.Date(2025, 2, 2, 1, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "cron skips to its first slot after the blackout",
+			cfg:      TimetableConfig{Cron: "0 * * * *", Blackouts: []string{"23:00-01:00"}},
+			now:      time.Date(2025, 2, 1, 22, 30, 0, 0, time.UTC),
+			expected: time.Date(2025, 2, 2, 1, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "blackout in local time",
+			cfg:      TimetableConfig{Cron: "0 * * * *", Blackouts: []string{"09:00-18:00"}, Location: "Europe/Berlin"},
+			now:      time.Date(2025, 2, 1, 7, 30, 0, 0, time.UTC), // 08:30 in Berlin
+			expected: time.Date(2025, 2, 1, 17, 0, 0, 0, time.UTC), // 18:00 in Berlin
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next, err := mustTimetable(t, tt.cfg).Next(tt.now)
+			if err != nil {
+				t.Fatalf("Next() error = %v", err)
+			}
+			if !next.Equal(tt.expected) {
+				t.Errorf("Next(%v) = %v, want %v", tt.now, next, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTimetable_JitterAvoidsBlackout(t *testing.T) {
+	tt := mustTimetable(t, TimetableConfig{
+		Cron:      "0 * * * *",
+		Blackouts: []string{"12:00-12:30"},
+		Jitter:    10 * time.Minute,
+	})
+
+	var draws []time.Duration
+	tt.random = func(n time.Duration) time.Duration {
+		draws = append(draws, n)
+		return 5 * time.Minute
+	}
+
+	// 11:00 + 5m is clear of the window
+	next, err := tt.Next(time.Date(2025, 2, 1, 10, 30, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+	if want := time.Date(2025, 2, 1, 11, 5, 0, 0, time.UTC); !next.Equal(want) {
+		t.Errorf("Next() = %v, want %v", next, want)
+	}
+
+	// 12:00 + 5m falls into the window, so the 13:00 slot is jittered instead
+	next, err = tt.Next(time.Date(2025, 2, 1, 11, 30, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+	if want := time.Date(2025, 2, 1, 13, 5, 0, 0, time.UTC); !next.Equal(want) {
+		t.Errorf("Next() = %v, want %v", next, want)
+	}
+	if len(draws) != 3 || draws[0] != 10*time.Minute {
+		t.Errorf("random draws = %v, want three draws of 10m", draws)
+	}
+}
+
+func TestNewTimetable_Invalid(t *testing.T) {
+	configs := []TimetableConfig{
+		{},
+		{Cron: "not a cron"},
+		{Period: time.Minute, Location: "Mars/Olympus"},
+		{Period: time.Minute, Blackouts: []string{"25:00-01:00"}},
+		{Period: time.Minute, Blackouts: []string{"10:00"}},
+		{Period: time.Minute, Blackouts: []string{"10:00-10:00"}},
+		{Period: time.Minute, Jitter: -time.Second},
+	}
+
+	for _, cfg := range configs {
+		if _, err := NewTimetable(cfg); err == nil {
+			t.Errorf("NewTimetable(%+v) error = nil, want an error", cfg)
+		}
+	}
+
+	// together the windows cover the whole day
+	_, err := NewTimetable(TimetableConfig{Period: time.Minute, Blackouts: []string{"00:00-12:00", "12:00-00:00"}})
+	if !errors.Is(err, ErrNoRunTime) {
+		t.Errorf("NewTimetable() error = %v, want %v", err, ErrNoRunTime)
+	}
+}
+
+func TestScheduler_RunFollowsTimetable(t *testing.T) {
+	start := time.Date(2025, 2, 1, 10, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: start, waits: make(chan time.Duration), fire: make(chan time.Time)}
+
+	// dry run on a replica that is not the leader touches no database
+	s := &Scheduler{
+		timetable: mustTimetable(t, TimetableConfig{Period: 5 * time.Minute}),
+		clock:     clock,
+		strategy:  OddLatestStrategy{},
+		leader:    fakeLeader{},
+		dryRun:    true,
+		log:       zap.NewNop(),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+
+	// the first cycle starts right away
+	if d := <-clock.waits; d != 0 {
+		t.Errorf("first wait = %v, want 0", d)
+	}
+	if next, ok := s.NextRun(); !ok || !next.Equal(start) {
+		t.Errorf("NextRun() = %v, %v, want %v, true", next, ok, start)
+	}
+
+	clock.fire <- start
+	if d := <-clock.waits; d != 5*time.Minute {
+		t.Errorf("second wait = %v, want 5m", d)
+	}
+	if next, ok := s.NextRun(); !ok || !next.Equal(start.Add(5*time.Minute)) {
+		t.Errorf("NextRun() = %v, %v, want %v, true", next, ok, start.Add(5*time.Minute))
+	}
+
+	cancel()
+	<-done
+	if _, ok := s.NextRun(); ok {
+		t.Error("NextRun() ok after the scheduler stopped")
+	}
+}
+
+func TestScheduler_Liveness(t *testing.T) {
+	start := time.Date(2025, 2, 1, 10, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		ts := start.Add(d)
+		return &ts
+	}
+	maxAge := 15 * time.Minute
+
+	s := &Scheduler{}
+	if err := s.Liveness(start, maxAge); err != nil {
+		t.Errorf("Liveness() before Run = %v, want nil", err)
+	}
+
+	s.started.Store(at(0))
+	if err := s.Liveness(start, maxAge); err == nil {
+		t.Error("Liveness() after Run returned = nil, want an error")
+	}
+
+	s.enabled.Store(true)
+	s.nextRun.Store(at(5 * time.Minute))
+	if err := s.Liveness(start.Add(10*time.Minute), maxAge); err != nil {
+		t.Errorf("Liveness() with a cycle in progress = %v, want nil", err)
+	}
+	// the cycle due at 10:05 never finished
+	if err := s.Liveness(start.Add(30*time.Minute), maxAge); err == nil {
+		t.Error("Liveness() with a stuck cycle = nil, want an error")
+	}
+
+	// cycles have failed since 10:05
+	s.nextRun.Store(at(time.Hour))
+	s.lastOK.Store(at(5 * time.Minute))
+	s.lastPass.Store(at(25 * time.Minute))
+	if err := s.Liveness(start.Add(15*time.Minute), maxAge); err != nil {
+		t.Errorf("Liveness() after a recent success = %v, want nil", err)
+	}
+	if err := s.Liveness(start.Add(30*time.Minute), maxAge); err == nil {
+		t.Error("Liveness() after failing past maxAge = nil, want an error")
+	}
+
+	s.lastOK.Store(at(25 * time.Minute))
+	if err := s.Liveness(start.Add(30*time.Minute), maxAge); err != nil {
+		t.Errorf("Liveness() after the last pass succeeded = %v, want nil", err)
+	}
+}
+
+func TestCycleRun_Claim(t *testing.T) {
+	c := newCycleRun(&domain.SchedulerRun{}, time.Time{}, nil)
+
+	first := c.claim([]CandidateOperation{{ID: 1}, {ID: 2}})
+	if len(first) != 2 {
+		t.Fatalf("claim() = %v, want both candidates", first)
+	}
+
+	// a candidate attempted earlier in the cycle is not attempted again
+	second := c.claim([]CandidateOperation{{ID: 2}, {ID: 3}})
+	if len(second) != 1 || second[0].ID != 3 {
+		t.Errorf("claim() = %v, want only operation 3", second)
+	}
+}
+
+func TestCycleRun_Expired(t *testing.T) {
+	start := time.Date(2025, 2, 1, 10, 0, 0, 0, time.UTC)
+
+	unlimited := newCycleRun(&domain.SchedulerRun{}, time.Time{}, nil)
+	if unlimited.expired(start.Add(24 * time.Hour)) {
+		t.Error("expired() = true without a budget")
+	}
+
+	c := newCycleRun(&domain.SchedulerRun{}, start.Add(time.Minute), nil)
+	if c.expired(start) || c.overBudget {
+		t.Error("expired() = true before the deadline")
+	}
+	if !c.expired(start.Add(time.Minute)) || !c.overBudget {
+		t.Error("expired() = false at the deadline")
+	}
+}
+
+func TestScheduler_LivenessDuringDatabaseOutage(t *testing.T) {
+	start := time.Date(2025, 2, 1, 10, 0, 0, 0, time.UTC)
+	maxAge := 15 * time.Minute
+
+	tests := []struct {
+		name     string
+		conn     *recordingConn
+		wantLive bool
+	}{
+		// readiness takes the replica out of rotation; a restart would not help
+		{"database unreachable", &recordingConn{connectErr: errors.New("connection refused")}, true},
+		{"cycles failing", &recordingConn{fail: map[string]error{"SELECT paused": errors.New("relation does not exist")}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{now: start}
+			s := &Scheduler{db: newRecordingDB(tt.conn), clock: clock, leader: fakeLeader{}, log: zap.NewNop()}
+			s.started.Store(&start)
+			s.enabled.Store(true)
+
+			clock.now = start.Add(20 * time.Minute)
+			s.runOnce(context.Background())
+
+			err := s.Liveness(clock.now, maxAge)
+			if live := err == nil; live != tt.wantLive {
+				t.Errorf("Liveness() = %v, want live %v", err, tt.wantLive)
+			}
+		})
+	}
+}
+
+func TestCreateCompensatingOperation_TxIDTaken(t *testing.T) {
+	s := &Scheduler{}
+	op := &domain.Operation{ID: 1, TxID: "tx-1", State: domain.StateDeposit, Amount: decimal.NewFromInt(10)}
+
+	conn := &recordingConn{}
+	tx := beginRecorded(t, conn)
+	if err := s.createCompensatingOperation(context.Background(), tx, op, domain.CompensatingTxID(op.TxID), decimal.NewFromInt(-10), "note"); err != nil {
+		t.Fatalf("createCompensatingOperation() error = %v", err)
+	}
+
+	// another operation already has the compensation's tx_id
+	conn = &recordingConn{conflict: []string{"INSERT INTO operations"}}
+	tx = beginRecorded(t, conn)
+	if err := s.createCompensatingOperation(context.Background(), tx, op, domain.CompensatingTxID(op.TxID), decimal.NewFromInt(-10), "note"); err == nil {
+		t.Error("createCompensatingOperation() = nil with the tx_id taken, want an error")
+	}
+}
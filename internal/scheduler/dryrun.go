@@ -0,0 +1,197 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/domain"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// DryRunBalance is an account touched by a candidate, before and after its
+// cancellation would be applied. Both are equal for a skipped candidate.
+type DryRunBalance struct {
+	AccountID uuid.UUID
+	Before    decimal.Decimal
+	After     decimal.Decimal
+}
+
+// DryRunCandidate is what a cycle would do with one candidate: cancel it,
+// skip it for insufficient funds, or find it no longer needs cancelling.
+type DryRunCandidate struct {
+	OperationID int64
+	TxID        string
+	Outcome     domain.CancelOutcome
+	Balances    []DryRunBalance
+}
+
+type DryRunReport struct {
+	Strategy   string
+	TakenAt    time.Time
+	Candidates []DryRunCandidate
+}
+
+// simulatedAccount is an account with the would-be cancellations of earlier candidates applied.
+type simulatedAccount struct {
+	balance decimal.Decimal
+	held    decimal.Decimal
+}
+
+// DryRun selects candidates and checks their balances the way a cycle would,
+// in a read-only snapshot, and logs what the cycle would do. It covers every
+// batch of the cycle: the due retries, up to MaxCandidates of them, then the
+// candidates the cycle selects at its start; a cycle that runs out of budget
+// leaves the last of them for the next one. Each candidate sees the balances
+// left by the cancellations before it.
+func (s *Scheduler) DryRun(ctx context.Context) (*DryRunReport, error) {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	// nothing to commit: the transaction is only a consistent snapshot
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			s.log.Error("failed to rollback transaction", zap.Error(err))
+		}
+	}()
+
+	report := &DryRunReport{Strategy: s.strategy.Name()}
+	if err := tx.QueryRowContext(ctx, `SELECT now()`).Scan(&report.TakenAt); err != nil {
+		return nil, err
+	}
+
+	candidates, err := selectDueRetries(ctx, tx, s.batch.MaxCandidates, false)
+	if err != nil {
+		return nil, fmt.Errorf("select due retries: %w", err)
+	}
+	fresh, err := s.strategy.Select(ctx, tx, s.batch.MaxCandidates)
+	if err != nil {
+		return nil, fmt.Errorf("select candidates (strategy %s): %w", s.strategy.Name(), err)
+	}
+	candidates = append(candidates, fresh...)
+
+	accounts := make(map[uuid.UUID]*simulatedAccount)
+	for _, candidate := range candidates {
+		result, err := s.simulateOne(ctx, tx, candidate, accounts)
+		if err != nil {
+			return nil, fmt.Errorf("simulate cancellation (op %d): %w", candidate.ID, err)
+		}
+		report.Candidates = append(report.Candidates, *result)
+	}
+
+	s.logDryRun(report)
+	return report, nil
+}
+
+func (s *Scheduler) simulateOne(ctx context.Context, tx *sql.Tx, candidate CandidateOperation, accounts map[uuid.UUID]*simulatedAccount) (*DryRunCandidate, error) {
+	operation, err := s.loadOperation(ctx, tx, candidate.ID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	legs, err := s.loadLegs(ctx, tx, operation, false)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, leg := range legs {
+		if _, err := s.simulatedAccount(ctx, tx, accounts, leg.AccountID); err != nil {
+			return nil, err
+		}
+	}
+	return s.simulateLegs(ctx, candidate, legs, accounts)
+}
+
+// simulateLegs decides a candidate whose accounts are loaded the way
+// cancelCandidate does, and applies a would-be cancellation to accounts.
+func (s *Scheduler) simulateLegs(ctx context.Context, candidate CandidateOperation, legs []*domain.Operation, accounts map[uuid.UUID]*simulatedAccount) (*DryRunCandidate, error) {
+	result := &DryRunCandidate{OperationID: candidate.ID, TxID: candidate.TxID}
+
+	for _, leg := range legs {
+		if !leg.Applied || leg.CanceledAt != nil {
+			result.Outcome = domain.CancelOutcomeNotApplicable
+			return result, nil
+		}
+	}
+
+	before := make(map[uuid.UUID]decimal.Decimal, len(legs))
+	for _, leg := range legs {
+		before[leg.AccountID] = accounts[leg.AccountID].balance
+	}
+
+	// the same guard as updateAccountBalance: available funds stay non-negative
+	_, applied, err := s.applyCompensatingDeltas(ctx, legs, func(_ context.Context, accountID uuid.UUID, delta decimal.Decimal) (*domain.Account, error) {
+		acc := accounts[accountID]
+		balance := acc.balance.Add(delta)
+		if balance.Sub(acc.held).IsNegative() {
+			return nil, nil
+		}
+		acc.balance = balance
+		return &domain.Account{ID: accountID, Balance: balance, Held: acc.held}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.Outcome = domain.CancelOutcomeCancelled
+	if !applied {
+		result.Outcome = domain.CancelOutcomeSkipped
+		for id, balance := range before {
+			accounts[id].balance = balance
+		}
+	}
+
+	for _, leg := range legs {
+		result.Balances = append(result.Balances, DryRunBalance{
+			AccountID: leg.AccountID,
+			Before:    before[leg.AccountID],
+			After:     accounts[leg.AccountID].balance,
+		})
+	}
+	return result, nil
+}
+
+func (s *Scheduler) simulatedAccount(ctx context.Context, tx *sql.Tx, accounts map[uuid.UUID]*simulatedAccount, id uuid.UUID) (*simulatedAccount, error) {
+	if acc, ok := accounts[id]; ok {
+		return acc, nil
+	}
+
+	acc := &simulatedAccount{}
+	if err := tx.QueryRowContext(ctx, `SELECT balance, held FROM accounts WHERE id = $1`, id).Scan(&acc.balance, &acc.held); err != nil {
+		return nil, fmt.Errorf("load account %s: %w", id, err)
+	}
+	accounts[id] = acc
+	return acc, nil
+}
+
+func (s *Scheduler) logDryRun(report *DryRunReport) {
+	counts := make(map[domain.CancelOutcome]int)
+	for _, c := range report.Candidates {
+		counts[c.Outcome]++
+
+		balances := make([]zap.Field, 0, len(c.Balances))
+		for _, b := range c.Balances {
+			balances = append(balances, zap.Dict(b.AccountID.String(),
+				zap.String("before", b.Before.String()),
+				zap.String("after", b.After.String())))
+		}
+		s.log.Info("dry run: candidate",
+			zap.Int64("op_id", c.OperationID),
+			zap.String("tx_id", c.TxID),
+			zap.String("outcome", string(c.Outcome)),
+			zap.Dict("balances", balances...))
+	}
+
+	s.log.Info("dry run completed",
+		zap.String("strategy", report.Strategy),
+		zap.Time("taken_at", report.TakenAt),
+		zap.Int("total_candidates", len(report.Candidates)),
+		zap.Int("would_cancel", counts[domain.CancelOutcomeCancelled]),
+		zap.Int("would_skip", counts[domain.CancelOutcomeSkipped]),
+		zap.Int("not_applicable", counts[domain.CancelOutcomeNotApplicable]))
+}
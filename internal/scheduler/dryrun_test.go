@@ -0,0 +1,180 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/domain"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// guardedAccounts applies deltas like updateAccountBalance: the UPDATE only
+// matches while balance - held + delta >= 0.
+type guardedAccounts map[uuid.UUID]*domain.Account
+
+func (a guardedAccounts) update(_ context.Context, accountID uuid.UUID, delta decimal.Decimal) (*domain.Account, error) {
+	acc := a[accountID]
+	if acc.Balance.Sub(acc.Held).Add(delta).IsNegative() {
+		return nil, nil
+	}
+	acc.Balance = acc.Balance.Add(delta)
+	updated := *acc
+	return &updated, nil
+}
+
+// cancel runs legs through the real compensation path, with the savepoint
+// rollback of compensate done by hand.
+func (a guardedAccounts) cancel(t *testing.T, s *Scheduler, legs []*domain.Operation) domain.CancelOutcome {
+	t.Helper()
+	for _, leg := range legs {
+		if !leg.Applied || leg.CanceledAt != nil {
+			return domain.CancelOutcomeNotApplicable
+		}
+	}
+
+	saved := make(map[uuid.UUID]decimal.Decimal)
+	for _, leg := range legs {
+		saved[leg.AccountID] = a[leg.AccountID].Balance
+	}
+
+	_, applied, err := s.applyCompensatingDeltas(context.Background(), legs, a.update)
+	if err != nil {
+		t.Fatalf("applyCompensatingDeltas() error = %v", err)
+	}
+	if !applied {
+		for id, balance := range saved {
+			a[id].Balance = balance
+		}
+		return domain.CancelOutcomeSkipped
+	}
+	return domain.CancelOutcomeCancelled
+}
+
+func TestDryRun_MatchesCancellation(t *testing.T) {
+	s := &Scheduler{log: zap.NewNop()}
+	alice, bob := uuid.New(), uuid.New()
+	canceledAt := time.Date(2025, 2, 1, 10, 0, 0, 0, time.UTC)
+
+	op := func(id int64, account uuid.UUID, state domain.State, amount int64) *domain.Operation {
+		return &domain.Operation{ID: id, TxID: "tx", AccountID: account, State: state, Amount: decimal.NewFromInt(amount), Applied: true}
+	}
+
+	// the candidates of one cycle, in order; each sees what the ones before it did
+	candidates := []struct {
+		name     string
+		legs     func() []*domain.Operation
+		expected domain.CancelOutcome
+	}{
+		{
+			name:     "deposit within available funds",
+			legs:     func() []*domain.Operation { return []*domain.Operation{op(1, alice, domain.StateDeposit, 50)} },
+			expected: domain.CancelOutcomeCancelled,
+		},
+		{
+			// 50 - 40 leaves 10, less than the 20 held
+			name:     "deposit eating into held funds",
+			legs:     func() []*domain.Operation { return []*domain.Operation{op(2, alice, domain.StateDeposit, 40)} },
+			expected: domain.CancelOutcomeSkipped,
+		},
+		{
+			name:     "withdraw",
+			legs:     func() []*domain.Operation { return []*domain.Operation{op(3, bob, domain.StateWithdraw, 5)} },
+			expected: domain.CancelOutcomeCancelled,
+		},
+		{
+			// bob cannot give back 30; alice's refund is not applied either
+			name: "transfer the receiver cannot return",
+			legs: func() []*domain.Operation {
+				return []*domain.Operation{op(4, alice, domain.StateWithdraw, 30), op(5, bob, domain.StateDeposit, 30)}
+			},
+			expected: domain.CancelOutcomeSkipped,
+		},
+		{
+			name: "already canceled",
+			legs: func() []*domain.Operation {
+				canceled := op(6, alice, domain.StateDeposit, 1)
+				canceled.CanceledAt = &canceledAt
+				return []*domain.Operation{canceled}
+			},
+			expected: domain.CancelOutcomeNotApplicable,
+		},
+		{
+			name: "transfer back",
+			legs: func() []*domain.Operation {
+				return []*domain.Operation{op(7, bob, domain.StateWithdraw, 10), op(8, alice, domain.StateDeposit, 10)}
+			},
+			expected: domain.CancelOutcomeCancelled,
+		},
+	}
+
+	real := guardedAccounts{
+		alice: {ID: alice, Balance: decimal.NewFromInt(100), Held: decimal.NewFromInt(20)},
+		bob:   {ID: bob, Balance: decimal.NewFromInt(10)},
+	}
+	simulated := map[uuid.UUID]*simulatedAccount{
+		alice: {balance: decimal.NewFromInt(100), held: decimal.NewFromInt(20)},
+		bob:   {balance: decimal.NewFromInt(10)},
+	}
+
+	for i, c := range candidates {
+		result, err := s.simulateLegs(context.Background(), CandidateOperation{ID: int64(i)}, c.legs(), simulated)
+		if err != nil {
+			t.Fatalf("%s: simulateLegs() error = %v", c.name, err)
+		}
+		outcome := real.cancel(t, s, c.legs())
+
+		if outcome != c.expected {
+			t.Errorf("%s: cancellation outcome = %v, want %v", c.name, outcome, c.expected)
+		}
+		if result.Outcome != outcome {
+			t.Errorf("%s: dry run outcome = %v, cancellation outcome = %v", c.name, result.Outcome, outcome)
+		}
+		for _, b := range result.Balances {
+			if !b.After.Equal(real[b.AccountID].Balance) {
+				t.Errorf("%s: dry run balance of %s = %s, cancellation left %s", c.name, b.AccountID, b.After, real[b.AccountID].Balance)
+			}
+		}
+	}
+
+	expected := map[uuid.UUID]int64{alice: 40, bob: 25}
+	for id, balance := range expected {
+		if !real[id].Balance.Equal(decimal.NewFromInt(balance)) {
+			t.Errorf("balance of %s after the cycle = %s, want %d", id, real[id].Balance, balance)
+		}
+		if !simulated[id].balance.Equal(real[id].Balance) {
+			t.Errorf("dry run balance of %s = %s, cancellation left %s", id, simulated[id].balance, real[id].Balance)
+		}
+	}
+}
+
+func TestDryRun_SkipReportsUnchangedBalances(t *testing.T) {
+	s := &Scheduler{log: zap.NewNop()}
+	from, to := uuid.New(), uuid.New()
+	accounts := map[uuid.UUID]*simulatedAccount{
+		from: {balance: decimal.NewFromInt(0)},
+		to:   {balance: decimal.NewFromInt(5)},
+	}
+	legs := []*domain.Operation{
+		{ID: 1, AccountID: from, State: domain.StateWithdraw, Amount: decimal.NewFromInt(10), Applied: true},
+		{ID: 2, AccountID: to, State: domain.StateDeposit, Amount: decimal.NewFromInt(10), Applied: true},
+	}
+
+	result, err := s.simulateLegs(context.Background(), CandidateOperation{ID: 1}, legs, accounts)
+	if err != nil {
+		t.Fatalf("simulateLegs() error = %v", err)
+	}
+	if result.Outcome != domain.CancelOutcomeSkipped {
+		t.Fatalf("outcome = %v, want %v", result.Outcome, domain.CancelOutcomeSkipped)
+	}
+	for _, b := range result.Balances {
+		if !b.Before.Equal(b.After) {
+			t.Errorf("balance of %s went from %s to %s on a skip", b.AccountID, b.Before, b.After)
+		}
+	}
+	if !accounts[from].balance.IsZero() {
+		t.Errorf("refund of the skipped transfer was kept: balance = %s", accounts[from].balance)
+	}
+}
@@ -0,0 +1,192 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/db"
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/domain"
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/events"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+const expiredHoldsBatch = 100
+
+// HoldExpirer releases holds that were neither captured nor released before their TTL.
+type HoldExpirer struct {
+	db     *db.DB
+	period time.Duration
+	log    *zap.Logger
+}
+
+func NewHoldExpirer(database *db.DB, period time.Duration, log *zap.Logger) *HoldExpirer {
+	return &HoldExpirer{
+		db:     database,
+		period: period,
+		log:    log.Named("hold-expirer"),
+	}
+}
+
+func (e *HoldExpirer) Run(ctx context.Context) {
+	e.log.Info("starting hold expirer", zap.Duration("period", e.period))
+
+	ticker := time.NewTicker(e.period)
+	defer ticker.Stop()
+
+	e.runOnce(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			e.log.Info("hold expirer stopped")
+			return
+		case <-ticker.C:
+			e.runOnce(ctx)
+		}
+	}
+}
+
+func (e *HoldExpirer) runOnce(ctx context.Context) {
+	holds, err := e.selectExpiredHolds(ctx)
+	if err != nil {
+		e.log.Error("failed to select expired holds", zap.Error(err))
+		return
+	}
+	if len(holds) == 0 {
+		return
+	}
+
+	var expired, failed int
+	for _, txID := range holds {
+		ok, err := e.expireOne(ctx, txID)
+		if err != nil {
+			failed++
+			e.log.Warn("hold expiry failed", zap.String("hold_tx_id", txID), zap.Error(err))
+			continue
+		}
+		if ok {
+			expired++
+		}
+	}
+
+	e.log.Info("hold expiry cycle completed",
+		zap.Int("candidates", len(holds)),
+		zap.Int("expired", expired),
+		zap.Int("failed", failed))
+}
+
+func (e *HoldExpirer) selectExpiredHolds(ctx context.Context) ([]string, error) {
+	query := `
+		SELECT tx_id
+		FROM holds
+		WHERE closed_at IS NULL
+			AND expires_at <= now()
+		ORDER BY expires_at
+		LIMIT $1`
+
+	rows, err := e.db.QueryContext(ctx, query, expiredHoldsBatch)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var holds []string
+	for rows.Next() {
+		var txID string
+		if err := rows.Scan(&txID); err != nil {
+			return nil, err
+		}
+		holds = append(holds, txID)
+	}
+
+	return holds, rows.Err()
+}
+
+// expireOne releases a single hold; false means it was closed or locked by someone else.
+func (e *HoldExpirer) expireOne(ctx context.Context, holdTxID string) (bool, error) {
+	tx, err := e.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			e.log.Error("failed to rollback transaction", zap.Error(err))
+		}
+	}()
+
+	var accountID uuid.UUID
+	var amount decimal.Decimal
+	var source domain.Source
+	err = tx.QueryRowContext(ctx, `
+		SELECT h.account_id, h.amount, o.source
+		FROM holds h
+		JOIN operations o ON o.tx_id = h.tx_id
+		WHERE h.tx_id = $1
+			AND h.closed_at IS NULL
+			AND h.expires_at <= now()
+		FOR UPDATE OF h SKIP LOCKED`, holdTxID).Scan(&accountID, &amount, &source)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	releaseTxID := domain.ExpiredHoldTxID(holdTxID)
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO operations (tx_id, account_id, source, state, amount, applied)
+		VALUES ($1, $2, $3, $4, $5, TRUE)
+		ON CONFLICT (tx_id) DO NOTHING`,
+		releaseTxID, accountID, source, domain.StateRelease, amount,
+	)
+	if err != nil {
+		return false, err
+	}
+	// the hold is closed by this release, not by another operation's row
+	if rows, _ := res.RowsAffected(); rows != 1 {
+		return false, fmt.Errorf("release of hold %s: tx_id %s is already taken by another operation", holdTxID, releaseTxID)
+	}
+
+	acc := domain.Account{ID: accountID}
+	if err := tx.QueryRowContext(ctx, `
+		UPDATE accounts
+		SET held = held - $1, updated_at = now()
+		WHERE id = $2
+		RETURNING balance, held, updated_at`, amount, accountID).Scan(&acc.Balance, &acc.Held, &acc.UpdatedAt); err != nil {
+		return false, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE holds
+		SET closed_at = now(), closed_by = $2
+		WHERE tx_id = $1`, holdTxID, releaseTxID); err != nil {
+		return false, err
+	}
+
+	// the outcome a Release records, so GetOperation shows the same for an expiry
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE operations
+		SET status = 'ok',
+			balance_after = $2::numeric,
+			available_after = $3::numeric,
+			processed_at = now()
+		WHERE tx_id = $1`, releaseTxID, acc.Balance.String(), acc.Available().String()); err != nil {
+		return false, err
+	}
+
+	if err := events.PublishBalance(ctx, tx, domain.NewBalanceEvent(&acc, releaseTxID)); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,52 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+func expiringHoldConn() *recordingConn {
+	return &recordingConn{rows: map[string][]driver.Value{
+		"SELECT h.account_id, h.amount, o.source": {uuid.NewString(), "30", "game"},
+		"UPDATE accounts SET held = held - $1":    {"100", "0", time.Now()},
+	}}
+}
+
+func TestHoldExpirer_ExpireOne(t *testing.T) {
+	conn := expiringHoldConn()
+	e := NewHoldExpirer(newRecordingDB(conn), time.Minute, zap.NewNop())
+
+	expired, err := e.expireOne(context.Background(), "hold-1")
+	if err != nil || !expired {
+		t.Fatalf("expireOne() = %v, %v, want true, nil", expired, err)
+	}
+	// a replay or GetOperation of the release sees its outcome
+	if got := len(conn.executed("UPDATE operations SET status = 'ok'")); got != 1 {
+		t.Errorf("recorded outcomes = %d, want 1", got)
+	}
+	if got := len(conn.executed("COMMIT")); got != 1 {
+		t.Errorf("commits = %d, want 1", got)
+	}
+}
+
+func TestHoldExpirer_ExpireOne_TxIDTaken(t *testing.T) {
+	conn := expiringHoldConn()
+	conn.conflict = []string{"INSERT INTO operations"}
+	e := NewHoldExpirer(newRecordingDB(conn), time.Minute, zap.NewNop())
+
+	// another operation already has the release's tx_id
+	if _, err := e.expireOne(context.Background(), "hold-1"); err == nil {
+		t.Fatal("expireOne() = nil with the release tx_id taken, want an error")
+	}
+	if got := conn.executed("UPDATE accounts"); len(got) != 0 {
+		t.Errorf("balance updated with the release tx_id taken: %q", got)
+	}
+	if got := len(conn.executed("COMMIT")); got != 0 {
+		t.Errorf("commits = %d, want 0", got)
+	}
+}
@@ -8,6 +8,20 @@ type Canceler interface {
 	Run(ctx context.Context)
 }
 
+// Leader tells whether this replica may run work that must not run twice.
+type Leader interface {
+	ID() string
+	IsLeader() bool
+	Leadership(ctx context.Context) (context.Context, context.CancelFunc, bool)
+}
+
+// SelectionStrategy picks the operations a cancellation cycle tries to cancel.
+// A cycle selects once, when it starts, and locks the candidates batch by batch.
+type SelectionStrategy interface {
+	Name() string
+	Select(ctx context.Context, q Querier, limit int) ([]CandidateOperation, error)
+}
+
 type CandidateOperation struct {
 	ID   int64
 	TxID string
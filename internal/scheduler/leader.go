@@ -0,0 +1,190 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/db"
+	"go.uber.org/zap"
+)
+
+// LeaderElector makes one replica the leader by holding a session-level
+// advisory lock on a pinned connection. The lock lives exactly as long as
+// that session, so a replica whose connection dies loses it, and a
+// heartbeat on the same connection tells the replica when that happened.
+type LeaderElector struct {
+	db        *db.DB
+	id        string
+	key       int64
+	heartbeat time.Duration
+	log       *zap.Logger
+
+	mu     sync.Mutex
+	conn   *sql.Conn
+	lost   context.Context
+	resign context.CancelFunc
+}
+
+func NewLeaderElector(database *db.DB, key int64, heartbeat time.Duration, log *zap.Logger) *LeaderElector {
+	return &LeaderElector{
+		db:        database,
+		id:        replicaID(),
+		key:       key,
+		heartbeat: heartbeat,
+		log:       log.Named("leader"),
+	}
+}
+
+func (l *LeaderElector) Run(ctx context.Context) {
+	l.log.Info("starting leader election",
+		zap.String("id", l.id),
+		zap.Int64("key", l.key),
+		zap.Duration("heartbeat", l.heartbeat))
+
+	ticker := time.NewTicker(l.heartbeat)
+	defer ticker.Stop()
+	defer l.stepDown()
+
+	l.tick(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			l.log.Info("leader election stopped")
+			return
+		case <-ticker.C:
+			l.tick(ctx)
+		}
+	}
+}
+
+// ID identifies this replica as a lock holder.
+func (l *LeaderElector) ID() string {
+	return l.id
+}
+
+// IsLeader reports whether this replica held the lock at the last heartbeat.
+func (l *LeaderElector) IsLeader() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.conn != nil
+}
+
+// Leadership returns a context for work only the leader may do. It is
+// canceled when ctx is done or leadership is lost; ok is false if this
+// replica is not the leader.
+func (l *LeaderElector) Leadership(ctx context.Context) (context.Context, context.CancelFunc, bool) {
+	l.mu.Lock()
+	lost := l.lost
+	l.mu.Unlock()
+
+	if lost == nil || lost.Err() != nil {
+		return ctx, func() {}, false
+	}
+
+	leaderCtx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(lost, cancel)
+	return leaderCtx, func() {
+		stop()
+		cancel()
+	}, true
+}
+
+func (l *LeaderElector) tick(ctx context.Context) {
+	if l.IsLeader() {
+		if err := l.ping(ctx); err != nil {
+			l.log.Warn("leadership lost", zap.Error(err))
+			l.stepDown()
+		}
+		return
+	}
+
+	acquired, err := l.tryAcquire(ctx)
+	if err != nil {
+		l.log.Error("failed to acquire leadership", zap.Error(err))
+		return
+	}
+	if acquired {
+		l.log.Info("acquired leadership")
+	}
+}
+
+func (l *LeaderElector) tryAcquire(ctx context.Context) (bool, error) {
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", l.key).Scan(&acquired); err != nil {
+		discardConn(conn)
+		return false, err
+	}
+	if !acquired {
+		l.log.Debug("leader lock busy")
+		conn.Close()
+		return false, nil
+	}
+
+	lost, resign := context.WithCancel(context.Background())
+
+	l.mu.Lock()
+	l.conn, l.lost, l.resign = conn, lost, resign
+	l.mu.Unlock()
+	return true, nil
+}
+
+func (l *LeaderElector) ping(ctx context.Context) error {
+	l.mu.Lock()
+	conn := l.conn
+	l.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, l.heartbeat)
+	defer cancel()
+	return conn.PingContext(ctx)
+}
+
+// stepDown stops leader work first, then gives the lock back.
+func (l *LeaderElector) stepDown() {
+	l.mu.Lock()
+	conn, resign := l.conn, l.resign
+	l.conn, l.lost, l.resign = nil, nil, nil
+	l.mu.Unlock()
+
+	if conn == nil {
+		return
+	}
+	resign()
+
+	ctx, cancel := context.WithTimeout(context.Background(), l.heartbeat)
+	defer cancel()
+
+	var released bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_advisory_unlock($1)", l.key).Scan(&released); err != nil || !released {
+		// never hand a session that may still hold the lock back to the pool
+		l.log.Warn("failed to release leader lock, discarding connection", zap.Bool("released", released), zap.Error(err))
+		discardConn(conn)
+		return
+	}
+	conn.Close()
+}
+
+// replicaID is the host and process running this elector.
+func replicaID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s/%d", host, os.Getpid())
+}
+
+// discardConn closes the session behind conn instead of returning it to the pool.
+func discardConn(conn *sql.Conn) {
+	_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+	conn.Close()
+}
@@ -0,0 +1,34 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLeaderElector_Leadership(t *testing.T) {
+	l := &LeaderElector{}
+
+	if _, _, ok := l.Leadership(context.Background()); ok {
+		t.Fatal("Leadership() ok before acquiring the lock")
+	}
+
+	l.lost, l.resign = context.WithCancel(context.Background())
+
+	ctx, stop, ok := l.Leadership(context.Background())
+	if !ok {
+		t.Fatal("Leadership() not ok while leading")
+	}
+	defer stop()
+
+	if ctx.Err() != nil {
+		t.Fatal("leader context canceled while leading")
+	}
+
+	// losing the lock mid-cycle must cancel work started under it
+	l.resign()
+	<-ctx.Done()
+
+	if _, _, ok := l.Leadership(context.Background()); ok {
+		t.Fatal("Leadership() ok after losing the lock")
+	}
+}
@@ -0,0 +1,81 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/domain"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	cancelCycleDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "balance",
+		Subsystem: "cancel_scheduler",
+		Name:      "cycle_duration_seconds",
+		Help:      "Duration of cancellation cycles by trigger (schedule, manual).",
+		Buckets:   []float64{0.1, 0.5, 1, 5, 10, 30, 60, 120, 300},
+	}, []string{"trigger"})
+
+	cancelOutcomes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "balance",
+		Subsystem: "cancel_scheduler",
+		Name:      "outcomes_total",
+		Help:      "Cancellation attempts by result (cancelled, skipped, failed, not_applicable).",
+	}, []string{"result"})
+
+	cancelParked = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "balance",
+		Subsystem: "cancel_scheduler",
+		Name:      "parked_total",
+		Help:      "Cancellations parked for manual review after their last retry.",
+	})
+
+	reconciliationRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "balance",
+		Subsystem: "reconciliation",
+		Name:      "runs_total",
+		Help:      "Reconciliation runs by status (ok, drift, failed).",
+	}, []string{"status"})
+
+	reconciliationDriftedAccounts = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "balance",
+		Subsystem: "reconciliation",
+		Name:      "drifted_accounts",
+		Help:      "Accounts whose stored balance differed from the ledger in the last run.",
+	})
+
+	reconciliationAbsDrift = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "balance",
+		Subsystem: "reconciliation",
+		Name:      "drift_abs_sum",
+		Help:      "Sum of absolute drifts found in the last run.",
+	})
+
+	reconciliationLastRun = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "balance",
+		Subsystem: "reconciliation",
+		Name:      "last_run_timestamp_seconds",
+		Help:      "Finish time of the last completed run.",
+	})
+)
+
+func observeRun(run *domain.SchedulerRun, duration time.Duration) {
+	cancelCycleDuration.WithLabelValues(string(run.Trigger)).Observe(duration.Seconds())
+	cancelOutcomes.WithLabelValues(string(domain.CancelOutcomeCancelled)).Add(float64(run.Cancelled))
+	cancelOutcomes.WithLabelValues(string(domain.CancelOutcomeSkipped)).Add(float64(run.Skipped))
+	cancelOutcomes.WithLabelValues(string(domain.CancelOutcomeFailed)).Add(float64(run.Failed))
+	cancelOutcomes.WithLabelValues(string(domain.CancelOutcomeNotApplicable)).Add(float64(run.NotApplicable))
+	cancelParked.Add(float64(run.Parked))
+}
+
+// observeReconciliation counts a run by status; report is nil for a failed run.
+func observeReconciliation(status string, report *ReconciliationReport) {
+	reconciliationRuns.WithLabelValues(status).Inc()
+	if report == nil {
+		return
+	}
+	reconciliationDriftedAccounts.Set(float64(len(report.Drifts)))
+	reconciliationAbsDrift.Set(report.AbsDrift().InexactFloat64())
+	reconciliationLastRun.SetToCurrentTime()
+}
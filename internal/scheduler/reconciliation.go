@@ -0,0 +1,249 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/db"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+const ReconciliationLockKey = int64(0xBABA1ED6)
+
+// ErrReconciliationBusy means another replica is reconciling right now.
+var ErrReconciliationBusy = errors.New("reconciliation already running")
+
+// AccountDrift is an account whose stored balance is not the signed sum of
+// its applied operations, compensating cancel:: operations included.
+type AccountDrift struct {
+	AccountID     uuid.UUID
+	StoredBalance decimal.Decimal
+	LedgerBalance decimal.Decimal
+}
+
+func (d AccountDrift) Drift() decimal.Decimal {
+	return d.StoredBalance.Sub(d.LedgerBalance)
+}
+
+type ReconciliationReport struct {
+	RunID           int64
+	StartedAt       time.Time
+	AccountsChecked int
+	Drifts          []AccountDrift
+}
+
+// Status is the reconciliation_runs status of a completed run.
+func (r *ReconciliationReport) Status() string {
+	if len(r.Drifts) > 0 {
+		return "drift"
+	}
+	return "ok"
+}
+
+// AbsDrift is the sum of the absolute drifts, so opposite drifts of two
+// accounts do not cancel out.
+func (r *ReconciliationReport) AbsDrift() decimal.Decimal {
+	sum := decimal.Zero
+	for _, d := range r.Drifts {
+		sum = sum.Add(d.Drift().Abs())
+	}
+	return sum
+}
+
+// Reconciler checks accounts.balance against the operations ledger.
+type Reconciler struct {
+	db     *db.DB
+	period time.Duration
+	log    *zap.Logger
+}
+
+func NewReconciler(database *db.DB, period time.Duration, log *zap.Logger) *Reconciler {
+	return &Reconciler{
+		db:     database,
+		period: period,
+		log:    log.Named("reconciler"),
+	}
+}
+
+func (r *Reconciler) Run(ctx context.Context) {
+	r.log.Info("starting reconciler", zap.Duration("period", r.period))
+
+	ticker := time.NewTicker(r.period)
+	defer ticker.Stop()
+
+	r.runOnce(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.log.Info("reconciler stopped")
+			return
+		case <-ticker.C:
+			r.runOnce(ctx)
+		}
+	}
+}
+
+func (r *Reconciler) runOnce(ctx context.Context) {
+	if _, err := r.Reconcile(ctx); err != nil {
+		if errors.Is(err, ErrReconciliationBusy) {
+			r.log.Debug("reconciler: lock busy, skipping cycle")
+			return
+		}
+		r.log.Error("reconciliation failed", zap.Error(err))
+	}
+}
+
+// Reconcile runs one reconciliation, records it in reconciliation_runs and
+// reports drift through logs and metrics.
+func (r *Reconciler) Reconcile(ctx context.Context) (*ReconciliationReport, error) {
+	report, err := r.check(ctx)
+	if err != nil {
+		if errors.Is(err, ErrReconciliationBusy) {
+			return nil, err
+		}
+		observeReconciliation("failed", nil)
+		if _, recErr := r.recordRun(ctx, time.Now(), "failed", nil, err); recErr != nil {
+			r.log.Error("failed to record reconciliation run", zap.Error(recErr))
+		}
+		return nil, err
+	}
+
+	status := report.Status()
+
+	report.RunID, err = r.recordRun(ctx, report.StartedAt, status, report, nil)
+	if err != nil {
+		return nil, fmt.Errorf("record reconciliation run: %w", err)
+	}
+
+	for _, d := range report.Drifts {
+		r.log.Error("balance drift detected",
+			zap.Int64("run_id", report.RunID),
+			zap.String("account_id", d.AccountID.String()),
+			zap.String("stored_balance", d.StoredBalance.String()),
+			zap.String("ledger_balance", d.LedgerBalance.String()),
+			zap.String("drift", d.Drift().String()))
+	}
+
+	observeReconciliation(status, report)
+
+	r.log.Info("reconciliation completed",
+		zap.Int64("run_id", report.RunID),
+		zap.Int("accounts_checked", report.AccountsChecked),
+		zap.Int("drifted_accounts", len(report.Drifts)))
+
+	return report, nil
+}
+
+// check compares balances in one snapshot: accounts and operations are
+// always written in the same transaction, so they must agree.
+func (r *Reconciler) check(ctx context.Context) (*ReconciliationReport, error) {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			r.log.Error("failed to rollback transaction", zap.Error(err))
+		}
+	}()
+
+	var acquired bool
+	if err := tx.QueryRowContext(ctx, "SELECT pg_try_advisory_xact_lock($1)", ReconciliationLockKey).Scan(&acquired); err != nil {
+		return nil, fmt.Errorf("acquire reconciliation lock: %w", err)
+	}
+	if !acquired {
+		return nil, ErrReconciliationBusy
+	}
+
+	report := &ReconciliationReport{}
+	if err := tx.QueryRowContext(ctx, `SELECT now(), count(*) FROM accounts`).Scan(&report.StartedAt, &report.AccountsChecked); err != nil {
+		return nil, fmt.Errorf("count accounts: %w", err)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT a.id, a.balance, COALESCE(l.balance, 0)
+		FROM accounts a
+		LEFT JOIN (
+			SELECT account_id, sum(operation_delta(state, amount)) AS balance
+			FROM operations
+			WHERE applied
+			GROUP BY account_id
+		) l ON l.account_id = a.id
+		WHERE a.balance <> COALESCE(l.balance, 0)
+		ORDER BY a.id`)
+	if err != nil {
+		return nil, fmt.Errorf("select drifts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d AccountDrift
+		if err := rows.Scan(&d.AccountID, &d.StoredBalance, &d.LedgerBalance); err != nil {
+			return nil, fmt.Errorf("scan drift: %w", err)
+		}
+		report.Drifts = append(report.Drifts, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate drifts: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (r *Reconciler) recordRun(ctx context.Context, startedAt time.Time, status string, report *ReconciliationReport, runErr error) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			r.log.Error("failed to rollback transaction", zap.Error(err))
+		}
+	}()
+
+	var checked, drifted int
+	var errText *string
+	if report != nil {
+		checked, drifted = report.AccountsChecked, len(report.Drifts)
+	}
+	if runErr != nil {
+		msg := runErr.Error()
+		errText = &msg
+	}
+
+	var runID int64
+	if err := tx.QueryRowContext(ctx, `
+		INSERT INTO reconciliation_runs (started_at, status, accounts_checked, drifted_accounts, error)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`,
+		startedAt, status, checked, drifted, errText,
+	).Scan(&runID); err != nil {
+		return 0, err
+	}
+
+	if report != nil {
+		for _, d := range report.Drifts {
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO reconciliation_drifts (run_id, account_id, stored_balance, ledger_balance, drift)
+				VALUES ($1, $2, $3, $4, $5)`,
+				runID, d.AccountID, d.StoredBalance, d.LedgerBalance, d.Drift(),
+			); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return runID, nil
+}
@@ -0,0 +1,55 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestAccountDrift_Drift(t *testing.T) {
+	tests := []struct {
+		stored, ledger, expected string
+	}{
+		{stored: "100.00", ledger: "100.00", expected: "0"},
+		// the stored balance is ahead of the ledger
+		{stored: "150.50", ledger: "100.00", expected: "50.5"},
+		{stored: "90.00", ledger: "100.25", expected: "-10.25"},
+	}
+
+	for _, tt := range tests {
+		d := AccountDrift{
+			AccountID:     uuid.New(),
+			StoredBalance: decimal.RequireFromString(tt.stored),
+			LedgerBalance: decimal.RequireFromString(tt.ledger),
+		}
+		if result := d.Drift(); !result.Equal(decimal.RequireFromString(tt.expected)) {
+			t.Errorf("Drift() with stored %s, ledger %s = %s, want %s", tt.stored, tt.ledger, result, tt.expected)
+		}
+	}
+}
+
+func TestReconciliationReport_Summary(t *testing.T) {
+	clean := &ReconciliationReport{AccountsChecked: 3}
+	if status := clean.Status(); status != "ok" {
+		t.Errorf("Status() without drifts = %q, want ok", status)
+	}
+	if sum := clean.AbsDrift(); !sum.IsZero() {
+		t.Errorf("AbsDrift() without drifts = %s, want 0", sum)
+	}
+
+	drifted := &ReconciliationReport{
+		AccountsChecked: 3,
+		Drifts: []AccountDrift{
+			{StoredBalance: decimal.NewFromInt(110), LedgerBalance: decimal.NewFromInt(100)},
+			{StoredBalance: decimal.NewFromInt(40), LedgerBalance: decimal.NewFromInt(50)},
+		},
+	}
+	if status := drifted.Status(); status != "drift" {
+		t.Errorf("Status() with drifts = %q, want drift", status)
+	}
+	// +10 and -10 do not cancel out
+	if sum := drifted.AbsDrift(); !sum.Equal(decimal.NewFromInt(20)) {
+		t.Errorf("AbsDrift() = %s, want 20", sum)
+	}
+}
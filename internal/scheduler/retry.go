@@ -0,0 +1,144 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/domain"
+	"go.uber.org/zap"
+)
+
+// RetryReason is why the last cancellation attempt of an operation did not succeed.
+type RetryReason string
+
+const (
+	RetryReasonInsufficientFunds RetryReason = "insufficient_funds"
+	RetryReasonFailed            RetryReason = "failed"
+)
+
+// RetryPolicy spaces out cancellation attempts exponentially and parks the
+// operation for manual review after MaxAttempts.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// Backoff is the delay after the given number of failed attempts.
+func (p RetryPolicy) Backoff(attempts int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempts && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// CancelRetry is a cancellation waiting for its next attempt, or parked when ParkedAt is set.
+type CancelRetry struct {
+	OperationID   int64
+	TxID          string
+	Attempts      int
+	LastReason    RetryReason
+	NextAttemptAt time.Time
+	ParkedAt      *time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+func selectDueRetries(ctx context.Context, q Querier, limit int, lock bool) ([]CandidateOperation, error) {
+	query := `
+		SELECT o.id, o.tx_id
+		FROM cancel_retries r
+		JOIN operations o ON o.id = r.operation_id
+		WHERE r.parked_at IS NULL
+			AND r.next_attempt_at <= now()
+		ORDER BY r.next_attempt_at
+		LIMIT $1`
+	if lock {
+		query += `
+		FOR UPDATE OF o, r SKIP LOCKED`
+	}
+	return queryCandidates(ctx, q, query, limit)
+}
+
+// recordAttempt counts a failed attempt and schedules the next one, or parks
+// the operation once the policy is exhausted. It reports whether it parked.
+func (s *Scheduler) recordAttempt(ctx context.Context, tx *sql.Tx, candidate CandidateOperation, reason RetryReason) (bool, error) {
+	var attempts int
+	err := tx.QueryRowContext(ctx, `
+		SELECT attempts FROM cancel_retries WHERE operation_id = $1 FOR UPDATE`,
+		candidate.ID).Scan(&attempts)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, fmt.Errorf("select retry: %w", err)
+	}
+	attempts++
+
+	parked := attempts >= s.retry.MaxAttempts
+	delay := s.retry.Backoff(attempts)
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO cancel_retries (operation_id, tx_id, attempts, last_reason, next_attempt_at, parked_at)
+		VALUES ($1, $2, $3, $4, now() + $5::bigint * interval '1 second', CASE WHEN $6::boolean THEN now() END)
+		ON CONFLICT (operation_id) DO UPDATE
+		SET attempts = EXCLUDED.attempts,
+			last_reason = EXCLUDED.last_reason,
+			next_attempt_at = EXCLUDED.next_attempt_at,
+			parked_at = EXCLUDED.parked_at,
+			updated_at = now()`,
+		candidate.ID, candidate.TxID, attempts, reason, int64(delay/time.Second), parked,
+	); err != nil {
+		return false, fmt.Errorf("upsert retry: %w", err)
+	}
+
+	s.log.Debug("cancellation retry scheduled",
+		zap.Int64("op_id", candidate.ID),
+		zap.Int("attempts", attempts),
+		zap.Duration("delay", delay),
+		zap.Bool("parked", parked))
+	return parked, nil
+}
+
+// clearRetries forgets the retries of operations that no longer need cancelling.
+func (s *Scheduler) clearRetries(ctx context.Context, tx *sql.Tx, legs []*domain.Operation) error {
+	for _, leg := range legs {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM cancel_retries WHERE operation_id = $1`, leg.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParkedCancellations lists operations whose cancellation ran out of attempts, oldest first.
+func ParkedCancellations(ctx context.Context, q Querier, limit int) ([]CancelRetry, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT operation_id, tx_id, attempts, last_reason, next_attempt_at, parked_at, created_at, updated_at
+		FROM cancel_retries
+		WHERE parked_at IS NOT NULL
+		ORDER BY parked_at, operation_id
+		LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query parked cancellations: %w", err)
+	}
+	defer rows.Close()
+
+	var parked []CancelRetry
+	for rows.Next() {
+		var r CancelRetry
+		if err := rows.Scan(&r.OperationID, &r.TxID, &r.Attempts, &r.LastReason,
+			&r.NextAttemptAt, &r.ParkedAt, &r.CreatedAt, &r.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan parked cancellation: %w", err)
+		}
+		parked = append(parked, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate parked cancellations: %w", err)
+	}
+	return parked, nil
+}
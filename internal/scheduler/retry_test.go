@@ -0,0 +1,29 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: 5 * time.Minute, MaxDelay: time.Hour}
+
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{1, 5 * time.Minute},
+		{2, 10 * time.Minute},
+		{3, 20 * time.Minute},
+		{4, 40 * time.Minute},
+		{5, time.Hour},
+		// large attempt counts stay capped instead of overflowing
+		{100, time.Hour},
+	}
+
+	for _, tt := range tests {
+		if result := policy.Backoff(tt.attempts); result != tt.expected {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempts, result, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/domain"
+	"go.uber.org/zap"
+)
+
+// startRun records a cycle as running. Runs left running by a replica that
+// died can never finish, so they are marked abandoned first.
+func (s *Scheduler) startRun(ctx context.Context, trigger domain.SchedulerTrigger) (*domain.SchedulerRun, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			s.log.Error("failed to rollback transaction", zap.Error(err))
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE scheduler_runs
+		SET status = 'abandoned'
+		WHERE status = 'running'`)
+	if err != nil {
+		return nil, fmt.Errorf("abandon stale runs: %w", err)
+	}
+	if abandoned, _ := res.RowsAffected(); abandoned > 0 {
+		s.log.Warn("abandoned unfinished scheduler runs", zap.Int64("runs", abandoned))
+	}
+
+	run := &domain.SchedulerRun{
+		Holder:  s.leader.ID(),
+		Trigger: trigger,
+		Status:  domain.SchedulerRunRunning,
+	}
+	if err := tx.QueryRowContext(ctx, `
+		INSERT INTO scheduler_runs (holder, trigger, status)
+		VALUES ($1, $2, $3)
+		RETURNING id, started_at`,
+		run.Holder, run.Trigger, run.Status,
+	).Scan(&run.ID, &run.StartedAt); err != nil {
+		return nil, fmt.Errorf("insert run: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+func (s *Scheduler) recordOutcome(ctx context.Context, runID int64, outcome *domain.SchedulerRunOutcome) error {
+	return s.db.QueryRowContext(ctx, `
+		INSERT INTO scheduler_run_outcomes (run_id, operation_id, tx_id, result, parked)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING attempted_at`,
+		runID, outcome.OperationID, outcome.TxID, outcome.Result, outcome.Parked,
+	).Scan(&outcome.AttemptedAt)
+}
+
+func (s *Scheduler) finishRun(ctx context.Context, run *domain.SchedulerRun) error {
+	return s.db.QueryRowContext(ctx, `
+		UPDATE scheduler_runs
+		SET finished_at = now(),
+			status = $2,
+			candidates = $3,
+			cancelled = $4,
+			skipped = $5,
+			failed = $6,
+			not_applicable = $7,
+			parked = $8
+		WHERE id = $1
+		RETURNING finished_at`,
+		run.ID, run.Status, run.Candidates, run.Cancelled, run.Skipped, run.Failed, run.NotApplicable, run.Parked,
+	).Scan(&run.FinishedAt)
+}
+
+// ListRuns returns runs older than beforeID (all when zero), newest first.
+func (s *Scheduler) ListRuns(ctx context.Context, beforeID int64, limit int, withOutcomes bool) ([]*domain.SchedulerRun, error) {
+	var before any
+	if beforeID > 0 {
+		before = beforeID
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, started_at, finished_at, holder, trigger, status,
+			candidates, cancelled, skipped, failed, not_applicable, parked
+		FROM scheduler_runs
+		WHERE ($1::bigint IS NULL OR id < $1::bigint)
+		ORDER BY id DESC
+		LIMIT $2`, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query scheduler runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []*domain.SchedulerRun
+	for rows.Next() {
+		var run domain.SchedulerRun
+		if err := rows.Scan(
+			&run.ID, &run.StartedAt, &run.FinishedAt, &run.Holder, &run.Trigger, &run.Status,
+			&run.Candidates, &run.Cancelled, &run.Skipped, &run.Failed, &run.NotApplicable, &run.Parked,
+		); err != nil {
+			return nil, fmt.Errorf("scan scheduler run: %w", err)
+		}
+		runs = append(runs, &run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate scheduler runs: %w", err)
+	}
+
+	if withOutcomes {
+		for _, run := range runs {
+			if run.Outcomes, err = s.loadOutcomes(ctx, run.ID); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return runs, nil
+}
+
+func (s *Scheduler) loadOutcomes(ctx context.Context, runID int64) ([]domain.SchedulerRunOutcome, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT operation_id, tx_id, result, parked, attempted_at
+		FROM scheduler_run_outcomes
+		WHERE run_id = $1
+		ORDER BY attempted_at, operation_id`, runID)
+	if err != nil {
+		return nil, fmt.Errorf("query run outcomes: %w", err)
+	}
+	defer rows.Close()
+
+	var outcomes []domain.SchedulerRunOutcome
+	for rows.Next() {
+		var o domain.SchedulerRunOutcome
+		if err := rows.Scan(&o.OperationID, &o.TxID, &o.Result, &o.Parked, &o.AttemptedAt); err != nil {
+			return nil, fmt.Errorf("scan run outcome: %w", err)
+		}
+		outcomes = append(outcomes, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate run outcomes: %w", err)
+	}
+	return outcomes, nil
+}
+
+// Status reports this replica's view of the scheduler with the latest run.
+func (s *Scheduler) Status(ctx context.Context) (*domain.SchedulerStatus, error) {
+	st := &domain.SchedulerStatus{
+		Holder:   s.leader.ID(),
+		IsLeader: s.leader.IsLeader(),
+		Enabled:  s.enabled.Load(),
+		Running:  s.running.Load(),
+		DryRun:   s.dryRun,
+		Strategy: s.strategy.Name(),
+		Schedule: s.timetable.String(),
+	}
+	if next, ok := s.NextRun(); ok {
+		st.NextRunAt = &next
+	}
+
+	var reason sql.NullString
+	if err := s.db.QueryRowContext(ctx, `
+		SELECT paused, paused_at, pause_reason FROM scheduler_state`,
+	).Scan(&st.Paused, &st.PausedAt, &reason); err != nil {
+		return nil, fmt.Errorf("select scheduler state: %w", err)
+	}
+	st.PauseReason = reason.String
+
+	runs, err := s.ListRuns(ctx, 0, 1, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) > 0 {
+		st.LastRun = runs[0]
+	}
+	return st, nil
+}
+
+// Pause stops scheduled cycles on every replica from the next one on;
+// a cycle in progress runs to the end.
+func (s *Scheduler) Pause(ctx context.Context, reason string) error {
+	var pauseReason *string
+	if reason != "" {
+		pauseReason = &reason
+	}
+
+	if _, err := s.db.ExecContext(ctx, `
+		UPDATE scheduler_state
+		SET paused = TRUE,
+			paused_at = CASE WHEN paused THEN paused_at ELSE now() END,
+			pause_reason = $1,
+			updated_at = now()`, pauseReason); err != nil {
+		return fmt.Errorf("pause scheduler: %w", err)
+	}
+
+	s.log.Info("cancel scheduler paused", zap.String("reason", reason))
+	return nil
+}
+
+func (s *Scheduler) Resume(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `
+		UPDATE scheduler_state
+		SET paused = FALSE, paused_at = NULL, pause_reason = NULL, updated_at = now()`); err != nil {
+		return fmt.Errorf("resume scheduler: %w", err)
+	}
+
+	s.log.Info("cancel scheduler resumed")
+	return nil
+}
+
+func (s *Scheduler) isPaused(ctx context.Context) (bool, error) {
+	var paused bool
+	if err := s.db.QueryRowContext(ctx, `SELECT paused FROM scheduler_state`).Scan(&paused); err != nil {
+		return false, fmt.Errorf("select scheduler state: %w", err)
+	}
+	return paused, nil
+}
@@ -2,89 +2,361 @@ package scheduler
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/db"
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/domain"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
+var tracer = otel.Tracer("github.com/MaksimPozharskiy/grpc-balance-processor/internal/scheduler")
+
+// AdvisoryLockKey is the advisory lock held by the scheduler leader.
 const AdvisoryLockKey = int64(0xBABACAFE)
 
 type Scheduler struct {
-	db     *db.DB
-	period time.Duration
-	log    *zap.Logger
+	db        *db.DB
+	timetable *Timetable
+	clock     Clock
+	strategy  SelectionStrategy
+	leader    Leader
+	retry     RetryPolicy
+	batch     BatchConfig
+	dryRun    bool
+	log       *zap.Logger
+
+	// cycle keeps scheduled and manual cycles of this replica apart
+	cycle   sync.Mutex
+	enabled atomic.Bool
+	running atomic.Bool
+	nextRun atomic.Pointer[time.Time]
+
+	// liveness of the loop: when it started, last finished a pass, and last
+	// finished one without failing while the database was reachable
+	started  atomic.Pointer[time.Time]
+	lastPass atomic.Pointer[time.Time]
+	lastOK   atomic.Pointer[time.Time]
 }
 
-func NewCancelScheduler(database *db.DB, period time.Duration, log *zap.Logger) *Scheduler {
+func NewCancelScheduler(database *db.DB, timetable *Timetable, strategy SelectionStrategy, leader Leader, retry RetryPolicy, batch BatchConfig, dryRun bool, log *zap.Logger) *Scheduler {
+	batch.Size = max(batch.Size, 1)
+	batch.Workers = max(batch.Workers, 1)
+	batch.MaxCandidates = max(batch.MaxCandidates, batch.Size)
+
 	return &Scheduler{
-		db:     database,
-		period: period,
-		log:    log.Named("cancel-scheduler"),
+		db:        database,
+		timetable: timetable,
+		clock:     systemClock{},
+		strategy:  strategy,
+		leader:    leader,
+		retry:     retry,
+		batch:     batch,
+		dryRun:    dryRun,
+		log:       log.Named("cancel-scheduler"),
 	}
 }
 
 func (s *Scheduler) Run(ctx context.Context) {
-	s.log.Info("starting cancel scheduler", zap.Duration("period", s.period))
-
-	ticker := time.NewTicker(s.period)
-	defer ticker.Stop()
+	s.log.Info("starting cancel scheduler",
+		zap.Stringer("schedule", s.timetable),
+		zap.String("strategy", s.strategy.Name()),
+		zap.Int("batch_size", s.batch.Size),
+		zap.Int("workers", s.batch.Workers),
+		zap.Duration("budget", s.batch.Budget),
+		zap.Bool("dry_run", s.dryRun))
 
-	s.runOnce(ctx)
+	started := s.clock.Now()
+	s.started.Store(&started)
+	s.enabled.Store(true)
+	defer s.enabled.Store(false)
+	defer s.nextRun.Store(nil)
 
+	next, err := s.timetable.Start(s.clock.Now())
 	for {
+		if err != nil {
+			s.log.Error("cancel scheduler stopped: no next run time", zap.Error(err))
+			return
+		}
+
+		s.nextRun.Store(&next)
+		s.log.Debug("next cancellation cycle scheduled", zap.Time("at", next))
+
 		select {
 		case <-ctx.Done():
 			s.log.Info("cancel scheduler stopped")
 			return
-		case <-ticker.C:
+		case <-s.clock.After(next.Sub(s.clock.Now())):
 			s.runOnce(ctx)
 		}
+
+		next, err = s.timetable.Next(s.clock.Now())
 	}
 }
 
+// NextRun is when the background loop starts its next cycle; ok is false
+// while the loop is not running.
+func (s *Scheduler) NextRun() (time.Time, bool) {
+	next := s.nextRun.Load()
+	if next == nil {
+		return time.Time{}, false
+	}
+	return *next, true
+}
+
 func (s *Scheduler) runOnce(ctx context.Context) {
 	s.log.Debug("starting cancellation cycle")
 
-	if !s.tryAdvisoryLock(ctx) {
-		s.log.Debug("cancel scheduler: lock busy, skipping cycle")
-		return
+	ok := s.pass(ctx)
+	// a pass that failed because the database is unreachable says nothing
+	// about the loop: readiness reports the outage, and restarting the
+	// replica would not bring the database back
+	if !ok && ctx.Err() == nil && s.db.HealthCheck(ctx) != nil {
+		s.log.Debug("cancel scheduler: database unavailable, pass not counted as failed")
+		ok = true
+	}
+
+	now := s.clock.Now()
+	s.lastPass.Store(&now)
+	if ok {
+		s.lastOK.Store(&now)
+	}
+}
+
+// pass runs or skips one scheduled cycle and reports whether it did so
+// without failing.
+func (s *Scheduler) pass(ctx context.Context) bool {
+	// only the leader reports a dry run, so replicas do not log the same candidates
+	if s.dryRun && !s.leader.IsLeader() {
+		s.log.Debug("cancel scheduler: not the leader, skipping dry run")
+		return true
 	}
-	defer s.advisoryUnlock(ctx)
 
-	candidates, err := s.selectOddLatestOps(ctx)
+	// a pause stops the dry run too, so it does not report a cycle that would not run
+	paused, err := s.isPaused(ctx)
 	if err != nil {
-		s.log.Error("failed to select candidate operations", zap.Error(err))
-		return
-	}
-
-	s.log.Info("starting cancellation cycle", zap.Int("candidates", len(candidates)))
-
-	var cancelled, skipped, failed int
-
-	for _, candidate := range candidates {
-		switch result := s.cancelOne(ctx, candidate.ID); result {
-		case CancelResultSuccess:
-			cancelled++
-			s.log.Info("operation cancelled successfully",
-				zap.Int64("op_id", candidate.ID),
-				zap.String("tx_id", candidate.TxID))
-		case CancelResultSkipped:
-			skipped++
-			s.log.Info("operation skipped due to insufficient funds",
-				zap.Int64("op_id", candidate.ID),
-				zap.String("tx_id", candidate.TxID))
-		case CancelResultFailed:
-			failed++
-			s.log.Warn("operation cancellation failed",
-				zap.Int64("op_id", candidate.ID),
-				zap.String("tx_id", candidate.TxID))
+		s.log.Error("failed to read scheduler state", zap.Error(err))
+		return false
+	}
+	if paused {
+		s.log.Debug("cancel scheduler: paused, skipping cycle")
+		return true
+	}
+
+	if s.dryRun {
+		if _, err := s.DryRun(ctx); err != nil {
+			s.log.Error("dry run failed", zap.Error(err))
+			return false
 		}
+		return true
+	}
+
+	if _, err := s.runCycle(ctx, domain.SchedulerTriggerSchedule); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotSchedulerLeader):
+			s.log.Debug("cancel scheduler: not the leader, skipping cycle")
+		case errors.Is(err, domain.ErrSchedulerBusy):
+			s.log.Debug("cancel scheduler: cycle already running, skipping")
+		default:
+			s.log.Error("cancellation cycle failed", zap.Error(err))
+			return false
+		}
+	}
+	return true
+}
+
+// Liveness reports whether the background loop is healthy at now: it is
+// running, has not been stuck past a scheduled cycle for longer than maxAge,
+// and has not been failing for longer than maxAge. Passes that failed while
+// the database was unreachable do not count as failing.
+func (s *Scheduler) Liveness(now time.Time, maxAge time.Duration) error {
+	started := s.started.Load()
+	if started == nil {
+		// Run has not been called yet
+		return nil
+	}
+	if !s.enabled.Load() {
+		return errors.New("cancel scheduler is not running")
+	}
+
+	// during a cycle the next run is the time that cycle was due
+	if next, ok := s.NextRun(); ok && now.Sub(next) > maxAge {
+		return fmt.Errorf("cancellation cycle due at %s is %s late", next.Format(time.RFC3339), now.Sub(next).Round(time.Second))
 	}
 
+	lastPass, lastOK := s.lastPass.Load(), s.lastOK.Load()
+	if lastPass == nil || (lastOK != nil && !lastOK.Before(*lastPass)) {
+		return nil
+	}
+	since := started
+	if lastOK != nil {
+		since = lastOK
+	}
+	if now.Sub(*since) > maxAge {
+		return fmt.Errorf("no successful cancellation cycle since %s", since.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// RunNow runs one cycle right away. It fails unless this replica is the
+// leader, the scheduler is neither paused nor in dry-run mode and no cycle
+// is in progress.
+func (s *Scheduler) RunNow(ctx context.Context) (*domain.SchedulerRun, error) {
+	if s.dryRun {
+		return nil, domain.ErrSchedulerDryRun
+	}
+
+	paused, err := s.isPaused(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if paused {
+		return nil, domain.ErrSchedulerPaused
+	}
+	return s.runCycle(ctx, domain.SchedulerTriggerManual)
+}
+
+func (s *Scheduler) runCycle(ctx context.Context, trigger domain.SchedulerTrigger) (*domain.SchedulerRun, error) {
+	if !s.cycle.TryLock() {
+		return nil, domain.ErrSchedulerBusy
+	}
+	defer s.cycle.Unlock()
+
+	s.running.Store(true)
+	defer s.running.Store(false)
+
+	start := s.clock.Now()
+
+	// stops the cycle as soon as another replica may have taken over
+	ctx, stop, ok := s.leader.Leadership(ctx)
+	if !ok {
+		return nil, domain.ErrNotSchedulerLeader
+	}
+	defer stop()
+
+	// a manual cycle is linked to the request that started it, not part of it
+	ctx, span := tracer.Start(ctx, "cancel_scheduler.cycle",
+		trace.WithNewRoot(),
+		trace.WithLinks(trace.LinkFromContext(ctx)),
+		trace.WithAttributes(attribute.String("trigger", string(trigger))))
+	defer span.End()
+
+	// history outlives the cycle: it is written even after leadership is lost
+	history := context.WithoutCancel(ctx)
+
+	// candidates are ranked once per cycle: selecting again after every batch
+	// would rank the ones left anew, and odd_latest would then take the
+	// operations it passed over a batch earlier
+	pending, err := s.strategy.Select(ctx, s.db, s.batch.MaxCandidates)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("select candidates (strategy %s): %w", s.strategy.Name(), err)
+	}
+
+	run, err := s.startRun(history, trigger)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("start run: %w", err)
+	}
+	span.SetAttributes(attribute.Int64("run_id", run.ID))
+
+	s.log.Info("starting cancellation cycle",
+		zap.Int64("run_id", run.ID),
+		zap.String("trigger", string(trigger)),
+		zap.Int("selected", len(pending)))
+
+	var deadline time.Time
+	if s.batch.Budget > 0 {
+		deadline = s.clock.Now().Add(s.batch.Budget)
+	}
+	c := newCycleRun(run, deadline, pending)
+
+	var wg sync.WaitGroup
+	for range s.batch.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.drain(ctx, history, c, s.cancelBatch)
+		}()
+	}
+	wg.Wait()
+
+	run.Status = domain.SchedulerRunCompleted
+	switch {
+	case ctx.Err() != nil:
+		run.Status = domain.SchedulerRunInterrupted
+		s.log.Warn("cancellation cycle interrupted", zap.Int64("run_id", run.ID), zap.Error(ctx.Err()))
+	case c.overBudget:
+		s.log.Warn("cancellation cycle budget used up, remaining candidates wait for the next cycle",
+			zap.Int64("run_id", run.ID),
+			zap.Duration("budget", s.batch.Budget))
+	}
+	if err := s.finishRun(history, run); err != nil {
+		s.log.Error("failed to record scheduler run", zap.Int64("run_id", run.ID), zap.Error(err))
+	}
+	observeRun(run, s.clock.Now().Sub(start))
+	span.SetAttributes(
+		attribute.String("status", string(run.Status)),
+		attribute.Int("candidates", run.Candidates),
+		attribute.Int("cancelled", run.Cancelled),
+		attribute.Int("skipped", run.Skipped),
+		attribute.Int("failed", run.Failed))
+
 	s.log.Info("cancellation cycle completed",
-		zap.Int("total_candidates", len(candidates)),
-		zap.Int("cancelled", cancelled),
-		zap.Int("skipped", skipped),
-		zap.Int("failed", failed))
+		zap.Int64("run_id", run.ID),
+		zap.String("status", string(run.Status)),
+		zap.Int("total_candidates", run.Candidates),
+		zap.Int("cancelled", run.Cancelled),
+		zap.Int("skipped", run.Skipped),
+		zap.Int("failed", run.Failed),
+		zap.Int("parked", run.Parked))
+
+	return run, nil
+}
+
+// scheduleRetry queues candidate for another attempt in its own transaction
+// and reports whether it was parked instead.
+func (s *Scheduler) scheduleRetry(ctx context.Context, candidate CandidateOperation, reason RetryReason) bool {
+	// an interrupted attempt says nothing about the operation
+	if ctx.Err() != nil {
+		return false
+	}
+
+	parked, err := s.queueRetry(ctx, candidate, reason)
+	if err != nil {
+		s.log.Error("failed to schedule cancellation retry",
+			zap.Int64("op_id", candidate.ID),
+			zap.String("reason", string(reason)),
+			zap.Error(err))
+		return false
+	}
+	return parked
+}
+
+func (s *Scheduler) queueRetry(ctx context.Context, candidate CandidateOperation, reason RetryReason) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			s.log.Error("failed to rollback transaction", zap.Error(err))
+		}
+	}()
+
+	parked, err := s.recordAttempt(ctx, tx, candidate, reason)
+	if err != nil {
+		return false, err
+	}
+	return parked, tx.Commit()
 }
@@ -2,32 +2,187 @@ package scheduler
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
+	"time"
 
-	"go.uber.org/zap"
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/domain"
 )
 
-func (s *Scheduler) selectOddLatestOps(ctx context.Context) ([]CandidateOperation, error) {
-	query := `
-		WITH ranked AS (
-			SELECT id, tx_id
-			FROM (
-				SELECT o.*,
-					ROW_NUMBER() OVER (ORDER BY o.created_at DESC, o.id DESC) AS rn
-				FROM operations o
-				WHERE o.applied = TRUE
-					AND o.canceled_at IS NULL
-			) t
-			WHERE (rn % 2) = 1
-			ORDER BY rn
-			LIMIT 10
-		)
+// Built-in strategy names, selected with CANCEL_STRATEGY.
+const (
+	StrategyOddLatest        = "odd_latest"
+	StrategyAccountOddLatest = "account_odd_latest"
+	StrategyOlderThan        = "older_than"
+	StrategyFilter           = "filter"
+)
+
+// candidateConditions restrict every strategy to operations the scheduler can cancel.
+const candidateConditions = `
+	o.applied = TRUE
+	AND o.canceled_at IS NULL
+	-- holds, captures and releases are settled through the hold lifecycle
+	AND o.state IN ('deposit', 'withdraw')
+	-- a transfer is one candidate, represented by its debit leg
+	AND (o.transfer_id IS NULL OR o.state = 'withdraw')
+	-- operations already in the retry queue come back on their own schedule
+	AND NOT EXISTS (SELECT 1 FROM cancel_retries r WHERE r.operation_id = o.id)`
+
+// Querier is the part of *db.DB a strategy needs.
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// StrategyConfig describes the strategy to build and its parameters.
+type StrategyConfig struct {
+	Name      string
+	OlderThan time.Duration
+	Source    domain.Source
+	State     domain.State
+}
+
+func NewSelectionStrategy(cfg StrategyConfig) (SelectionStrategy, error) {
+	switch cfg.Name {
+	case StrategyOddLatest:
+		return OddLatestStrategy{}, nil
+	case StrategyAccountOddLatest:
+		return AccountOddLatestStrategy{}, nil
+	case StrategyOlderThan:
+		if cfg.OlderThan <= 0 {
+			return nil, fmt.Errorf("strategy %s: age must be positive", cfg.Name)
+		}
+		return OlderThanStrategy{Age: cfg.OlderThan}, nil
+	case StrategyFilter:
+		s := FilterStrategy{}
+		if cfg.Source != "" {
+			switch cfg.Source {
+			case domain.SourceGame, domain.SourcePayment, domain.SourceService:
+			default:
+				return nil, fmt.Errorf("strategy %s: unknown source %q", cfg.Name, cfg.Source)
+			}
+			s.Source = &cfg.Source
+		}
+		if cfg.State != "" {
+			if cfg.State != domain.StateDeposit && cfg.State != domain.StateWithdraw {
+				return nil, fmt.Errorf("strategy %s: state must be deposit or withdraw", cfg.Name)
+			}
+			s.State = &cfg.State
+		}
+		if s.Source == nil && s.State == nil {
+			return nil, fmt.Errorf("strategy %s: source or state is required", cfg.Name)
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown selection strategy %q", cfg.Name)
+	}
+}
+
+// OddLatestStrategy numbers candidates across all accounts, latest first,
+// and takes the odd ones.
+type OddLatestStrategy struct{}
+
+func (OddLatestStrategy) Name() string { return StrategyOddLatest }
+
+func (OddLatestStrategy) Select(ctx context.Context, q Querier, limit int) ([]CandidateOperation, error) {
+	return queryCandidates(ctx, q, `
+		SELECT id, tx_id
+		FROM (
+			SELECT o.id, o.tx_id,
+				ROW_NUMBER() OVER (ORDER BY o.created_at DESC, o.id DESC) AS rn
+			FROM operations o
+			WHERE `+candidateConditions+`
+		) t
+		WHERE (rn % 2) = 1
+		ORDER BY rn
+		LIMIT $1`, limit)
+}
+
+// AccountOddLatestStrategy numbers candidates within each account, latest
+// first, and takes the odd ones.
+type AccountOddLatestStrategy struct{}
+
+func (AccountOddLatestStrategy) Name() string { return StrategyAccountOddLatest }
+
+func (AccountOddLatestStrategy) Select(ctx context.Context, q Querier, limit int) ([]CandidateOperation, error) {
+	return queryCandidates(ctx, q, `
 		SELECT id, tx_id
-		FROM ranked;`
+		FROM (
+			SELECT o.id, o.tx_id, o.created_at,
+				ROW_NUMBER() OVER (PARTITION BY o.account_id ORDER BY o.created_at DESC, o.id DESC) AS rn
+			FROM operations o
+			WHERE `+candidateConditions+`
+		) t
+		WHERE (rn % 2) = 1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $1`, limit)
+}
+
+// OlderThanStrategy takes the latest candidates created more than Age ago.
+type OlderThanStrategy struct {
+	Age time.Duration
+}
+
+func (OlderThanStrategy) Name() string { return StrategyOlderThan }
+
+func (s OlderThanStrategy) Select(ctx context.Context, q Querier, limit int) ([]CandidateOperation, error) {
+	return queryCandidates(ctx, q, `
+		SELECT o.id, o.tx_id
+		FROM operations o
+		WHERE `+candidateConditions+`
+			AND o.created_at < now() - $2::bigint * interval '1 second'
+		ORDER BY o.created_at DESC, o.id DESC
+		LIMIT $1`, limit, int64(s.Age/time.Second))
+}
+
+// FilterStrategy takes the latest candidates with the given source and/or state.
+type FilterStrategy struct {
+	Source *domain.Source
+	State  *domain.State
+}
+
+func (FilterStrategy) Name() string { return StrategyFilter }
 
-	rows, err := s.db.QueryContext(ctx, query)
+func (s FilterStrategy) Select(ctx context.Context, q Querier, limit int) ([]CandidateOperation, error) {
+	var source, state any
+	if s.Source != nil {
+		source = string(*s.Source)
+	}
+	if s.State != nil {
+		state = string(*s.State)
+	}
+
+	return queryCandidates(ctx, q, `
+		SELECT o.id, o.tx_id
+		FROM operations o
+		WHERE `+candidateConditions+`
+			AND ($2::source_t IS NULL OR o.source = $2::source_t)
+			AND ($3::state_t IS NULL OR o.state = $3::state_t)
+		ORDER BY o.created_at DESC, o.id DESC
+		LIMIT $1`, limit, source, state)
+}
+
+// lockCandidates locks candidates selected earlier, in their order, for the
+// worker that cancels them. It leaves out those another worker or a manual
+// cancellation holds and those that are no longer candidates.
+func lockCandidates(ctx context.Context, q Querier, candidates []CandidateOperation) ([]CandidateOperation, error) {
+	ids := make([]int64, len(candidates))
+	for i, candidate := range candidates {
+		ids[i] = candidate.ID
+	}
+
+	return queryCandidates(ctx, q, `
+		SELECT o.id, o.tx_id
+		FROM operations o
+		WHERE o.id = ANY($1)
+			AND `+candidateConditions+`
+		ORDER BY array_position($1, o.id)
+		FOR UPDATE OF o SKIP LOCKED`, ids)
+}
+
+func queryCandidates(ctx context.Context, q Querier, query string, args ...any) ([]CandidateOperation, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
 	if err != nil {
-		s.log.Error("failed to query odd operations", zap.Error(err))
-		return nil, err
+		return nil, fmt.Errorf("query candidates: %w", err)
 	}
 	defer rows.Close()
 
@@ -35,17 +190,13 @@ func (s *Scheduler) selectOddLatestOps(ctx context.Context) ([]CandidateOperatio
 	for rows.Next() {
 		var candidate CandidateOperation
 		if err := rows.Scan(&candidate.ID, &candidate.TxID); err != nil {
-			s.log.Error("failed to scan candidate operation", zap.Error(err))
-			return nil, err
+			return nil, fmt.Errorf("scan candidate: %w", err)
 		}
 		candidates = append(candidates, candidate)
 	}
 
 	if err := rows.Err(); err != nil {
-		s.log.Error("error iterating candidate operations", zap.Error(err))
-		return nil, err
+		return nil, fmt.Errorf("iterate candidates: %w", err)
 	}
-
-	s.log.Debug("selected candidate operations", zap.Int("count", len(candidates)))
 	return candidates, nil
 }
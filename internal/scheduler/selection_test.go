@@ -0,0 +1,44 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/domain"
+)
+
+func TestNewSelectionStrategy(t *testing.T) {
+	tests := []struct {
+		cfg      StrategyConfig
+		expected string
+		wantErr  bool
+	}{
+		{StrategyConfig{Name: StrategyOddLatest}, StrategyOddLatest, false},
+		{StrategyConfig{Name: StrategyAccountOddLatest}, StrategyAccountOddLatest, false},
+		{StrategyConfig{Name: StrategyOlderThan, OlderThan: time.Hour}, StrategyOlderThan, false},
+		{StrategyConfig{Name: StrategyOlderThan}, "", true},
+		{StrategyConfig{Name: StrategyFilter, Source: domain.SourceGame}, StrategyFilter, false},
+		{StrategyConfig{Name: StrategyFilter, State: domain.StateWithdraw}, StrategyFilter, false},
+		{StrategyConfig{Name: StrategyFilter}, "", true},
+		{StrategyConfig{Name: StrategyFilter, State: domain.StateHold}, "", true},
+		{StrategyConfig{Name: StrategyFilter, Source: "casino"}, "", true},
+		{StrategyConfig{Name: "random"}, "", true},
+	}
+
+	for _, tt := range tests {
+		strategy, err := NewSelectionStrategy(tt.cfg)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("NewSelectionStrategy(%+v) expected error", tt.cfg)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("NewSelectionStrategy(%+v) error = %v", tt.cfg, err)
+			continue
+		}
+		if strategy.Name() != tt.expected {
+			t.Errorf("NewSelectionStrategy(%+v).Name() = %v, want %v", tt.cfg, strategy.Name(), tt.expected)
+		}
+	}
+}
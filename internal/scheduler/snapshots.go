@@ -0,0 +1,99 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/db"
+	"go.uber.org/zap"
+)
+
+// snapshotSettleDelay keeps snapshots clear of transactions that started
+// before the cutoff but have not committed yet.
+const snapshotSettleDelay = 10 * time.Minute
+
+// BalanceSnapshotter periodically stores every account's balance so that
+// point-in-time lookups only replay operations since the last snapshot.
+type BalanceSnapshotter struct {
+	db     *db.DB
+	period time.Duration
+	log    *zap.Logger
+}
+
+func NewBalanceSnapshotter(database *db.DB, period time.Duration, log *zap.Logger) *BalanceSnapshotter {
+	return &BalanceSnapshotter{
+		db:     database,
+		period: period,
+		log:    log.Named("balance-snapshotter"),
+	}
+}
+
+func (b *BalanceSnapshotter) Run(ctx context.Context) {
+	b.log.Info("starting balance snapshotter", zap.Duration("period", b.period))
+
+	ticker := time.NewTicker(b.period)
+	defer ticker.Stop()
+
+	b.runOnce(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			b.log.Info("balance snapshotter stopped")
+			return
+		case <-ticker.C:
+			b.runOnce(ctx)
+		}
+	}
+}
+
+func (b *BalanceSnapshotter) runOnce(ctx context.Context) {
+	takenAt := snapshotCutoff(time.Now(), b.period)
+
+	var exists bool
+	if err := b.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM balance_snapshots WHERE taken_at = $1)`, takenAt,
+	).Scan(&exists); err != nil {
+		b.log.Error("failed to check balance snapshot", zap.Error(err))
+		return
+	}
+	if exists {
+		b.log.Debug("balance snapshot already taken", zap.Time("taken_at", takenAt))
+		return
+	}
+
+	// replicas agree on takenAt, so a concurrent run inserts nothing twice
+	res, err := b.db.ExecContext(ctx, `
+		INSERT INTO balance_snapshots (account_id, taken_at, balance)
+		SELECT a.id, $1::timestamptz, COALESCE(s.balance, 0) + COALESCE((
+				SELECT sum(operation_delta(o.state, o.amount))
+				FROM operations o
+				WHERE o.account_id = a.id
+					AND o.applied
+					AND o.created_at >= COALESCE(s.taken_at, '-infinity'::timestamptz)
+					AND o.created_at < $1::timestamptz
+			), 0)
+		FROM accounts a
+		LEFT JOIN LATERAL (
+			SELECT taken_at, balance
+			FROM balance_snapshots
+			WHERE account_id = a.id AND taken_at < $1::timestamptz
+			ORDER BY taken_at DESC
+			LIMIT 1
+		) s ON true
+		ON CONFLICT (account_id, taken_at) DO NOTHING`, takenAt)
+	if err != nil {
+		b.log.Error("failed to take balance snapshot", zap.Time("taken_at", takenAt), zap.Error(err))
+		return
+	}
+
+	accounts, _ := res.RowsAffected()
+	b.log.Info("balance snapshot taken",
+		zap.Time("taken_at", takenAt),
+		zap.Int64("accounts", accounts))
+}
+
+// snapshotCutoff aligns snapshots to the period, lagging behind now by the settle delay.
+func snapshotCutoff(now time.Time, period time.Duration) time.Time {
+	return now.Add(-snapshotSettleDelay).Truncate(period).UTC()
+}
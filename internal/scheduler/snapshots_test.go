@@ -0,0 +1,26 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSnapshotCutoff(t *testing.T) {
+	tests := []struct {
+		now      time.Time
+		period   time.Duration
+		expected time.Time
+	}{
+		// daily snapshots land on midnight once the settle delay has passed
+		{time.Date(2025, 2, 1, 0, 5, 0, 0, time.UTC), 24 * time.Hour, time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2025, 2, 1, 0, 15, 0, 0, time.UTC), 24 * time.Hour, time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2025, 2, 1, 13, 47, 0, 0, time.UTC), time.Hour, time.Date(2025, 2, 1, 13, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		result := snapshotCutoff(tt.now, tt.period)
+		if !result.Equal(tt.expected) {
+			t.Errorf("snapshotCutoff(%v, %v) = %v, want %v", tt.now, tt.period, result, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// maxBlackoutSkips bounds the search for a run time outside blackout windows.
+const maxBlackoutSkips = 1000
+
+// ErrNoRunTime means blackout windows leave no time for a cycle.
+var ErrNoRunTime = errors.New("no run time outside blackout windows")
+
+// Clock is the time source of the scheduler loop, replaced in tests.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// TimetableConfig describes when cancellation cycles run. Cron, when set,
+// replaces Period; blackout windows are "HH:MM-HH:MM" in Location and may
+// wrap midnight.
+type TimetableConfig struct {
+	Period    time.Duration
+	Cron      string
+	Blackouts []string
+	Location  string
+	Jitter    time.Duration
+}
+
+// BlackoutWindow is a daily span of wall-clock minutes when no cycle starts.
+type BlackoutWindow struct {
+	Start int
+	End   int
+}
+
+func (w BlackoutWindow) contains(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	if w.Start < w.End {
+		return m >= w.Start && m < w.End
+	}
+	// wraps midnight
+	return m >= w.Start || m < w.End
+}
+
+// end returns when the window containing t is over.
+func (w BlackoutWindow) end(t time.Time) time.Time {
+	end := time.Date(t.Year(), t.Month(), t.Day(), w.End/60, w.End%60, 0, 0, t.Location())
+	if !end.After(t) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return end
+}
+
+func (w BlackoutWindow) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", w.Start/60, w.Start%60, w.End/60, w.End%60)
+}
+
+// ParseBlackoutWindow parses "HH:MM-HH:MM".
+func ParseBlackoutWindow(spec string) (BlackoutWindow, error) {
+	from, to, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return BlackoutWindow{}, fmt.Errorf("blackout window %q: want HH:MM-HH:MM", spec)
+	}
+
+	start, err := time.Parse("15:04", from)
+	if err != nil {
+		return BlackoutWindow{}, fmt.Errorf("blackout window %q: %w", spec, err)
+	}
+	end, err := time.Parse("15:04", to)
+	if err != nil {
+		return BlackoutWindow{}, fmt.Errorf("blackout window %q: %w", spec, err)
+	}
+
+	w := BlackoutWindow{Start: start.Hour()*60 + start.Minute(), End: end.Hour()*60 + end.Minute()}
+	if w.Start == w.End {
+		return BlackoutWindow{}, fmt.Errorf("blackout window %q is empty", spec)
+	}
+	return w, nil
+}
+
+// everySchedule runs a fixed period after the previous run.
+type everySchedule struct {
+	period time.Duration
+}
+
+func (s everySchedule) Next(t time.Time) time.Time {
+	return t.Add(s.period)
+}
+
+// Timetable decides when the next cancellation cycle starts.
+type Timetable struct {
+	schedule  cron.Schedule
+	spec      string
+	blackouts []BlackoutWindow
+	loc       *time.Location
+	jitter    time.Duration
+	// random returns a duration in [0, n)
+	random func(n time.Duration) time.Duration
+}
+
+func NewTimetable(cfg TimetableConfig) (*Timetable, error) {
+	t := &Timetable{
+		jitter: cfg.Jitter,
+		random: func(n time.Duration) time.Duration { return rand.N(n) },
+	}
+
+	var err error
+	if cfg.Location == "" {
+		cfg.Location = "UTC"
+	}
+	if t.loc, err = time.LoadLocation(cfg.Location); err != nil {
+		return nil, fmt.Errorf("timezone: %w", err)
+	}
+
+	if cfg.Cron != "" {
+		if t.schedule, err = cron.ParseStandard(cfg.Cron); err != nil {
+			return nil, fmt.Errorf("cron %q: %w", cfg.Cron, err)
+		}
+		t.spec = cfg.Cron
+	} else {
+		if cfg.Period <= 0 {
+			return nil, errors.New("period must be positive")
+		}
+		t.schedule = everySchedule{period: cfg.Period}
+		t.spec = "every " + cfg.Period.String()
+	}
+
+	if cfg.Jitter < 0 {
+		return nil, errors.New("jitter must not be negative")
+	}
+
+	for _, spec := range cfg.Blackouts {
+		if strings.TrimSpace(spec) == "" {
+			continue
+		}
+		w, err := ParseBlackoutWindow(spec)
+		if err != nil {
+			return nil, err
+		}
+		t.blackouts = append(t.blackouts, w)
+	}
+
+	// windows that cover the whole day are only found by looking
+	if _, err := t.Next(time.Now()); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// String describes the schedule, e.g. "every 5m0s" or a cron expression.
+func (t *Timetable) String() string {
+	return t.spec
+}
+
+// Start is the first run time: right away for a fixed period, the first
+// matching time for cron.
+func (t *Timetable) Start(now time.Time) (time.Time, error) {
+	if _, ok := t.schedule.(everySchedule); ok {
+		return t.fit(now)
+	}
+	return t.Next(now)
+}
+
+// Next is the first run time after now.
+func (t *Timetable) Next(now time.Time) (time.Time, error) {
+	return t.fit(t.schedule.Next(now.In(t.loc)))
+}
+
+// fit adds jitter to a scheduled time and moves it out of blackout windows.
+func (t *Timetable) fit(scheduled time.Time) (time.Time, error) {
+	scheduled = scheduled.In(t.loc)
+
+	for i := 0; i < maxBlackoutSkips; i++ {
+		at := scheduled
+		if t.jitter > 0 {
+			at = at.Add(t.random(t.jitter))
+		}
+
+		w, blocked := t.blackoutAt(at)
+		if !blocked {
+			return at, nil
+		}
+		scheduled = t.resume(w.end(at))
+	}
+	return time.Time{}, ErrNoRunTime
+}
+
+func (t *Timetable) blackoutAt(at time.Time) (BlackoutWindow, bool) {
+	for _, w := range t.blackouts {
+		if w.contains(at) {
+			return w, true
+		}
+	}
+	return BlackoutWindow{}, false
+}
+
+// resume is the first scheduled time once a blackout window ends: the end
+// itself for a fixed period, the next matching time for cron.
+func (t *Timetable) resume(end time.Time) time.Time {
+	if _, ok := t.schedule.(everySchedule); ok {
+		return end
+	}
+	// cron has second precision and returns times strictly after its argument
+	return t.schedule.Next(end.Add(-time.Second))
+}
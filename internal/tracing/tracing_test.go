@@ -0,0 +1,22 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSetup(t *testing.T) {
+	for _, exporter := range []string{ExporterNone, ExporterStdout} {
+		shutdown, err := Setup(context.Background(), Config{Exporter: exporter, Service: "test"})
+		if err != nil {
+			t.Fatalf("Setup(%q) error = %v", exporter, err)
+		}
+		if err := shutdown(context.Background()); err != nil {
+			t.Errorf("shutdown(%q) error = %v", exporter, err)
+		}
+	}
+
+	if _, err := Setup(context.Background(), Config{Exporter: "zipkin"}); err == nil {
+		t.Error("Setup(zipkin) error = nil, want an error")
+	}
+}
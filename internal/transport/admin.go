@@ -0,0 +1,180 @@
+package transport
+
+import (
+	"context"
+
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/domain"
+	adminpb "github.com/MaksimPozharskiy/grpc-balance-processor/proto/admin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const maxPauseReasonLength = 256
+
+type AdminServer struct {
+	adminpb.UnimplementedSchedulerAdminServiceServer
+	service domain.SchedulerAdminService
+}
+
+func NewAdminServer(service domain.SchedulerAdminService) *AdminServer {
+	return &AdminServer{
+		service: service,
+	}
+}
+
+func (s *AdminServer) GetSchedulerStatus(ctx context.Context, req *adminpb.GetSchedulerStatusRequest) (*adminpb.SchedulerStatus, error) {
+	st, err := s.service.GetSchedulerStatus(ctx)
+	if err != nil {
+		return nil, mapDomainError(err)
+	}
+	return mapDomainSchedulerStatus(st), nil
+}
+
+func (s *AdminServer) ListSchedulerRuns(ctx context.Context, req *adminpb.ListSchedulerRunsRequest) (*adminpb.ListSchedulerRunsResponse, error) {
+	if req.PageSize < 0 {
+		return nil, status.Error(codes.InvalidArgument, "page_size must not be negative")
+	}
+
+	resp, err := s.service.ListSchedulerRuns(ctx, &domain.ListSchedulerRunsRequest{
+		PageSize:        int(req.PageSize),
+		PageToken:       req.PageToken,
+		IncludeOutcomes: req.IncludeOutcomes,
+	})
+	if err != nil {
+		return nil, mapDomainError(err)
+	}
+
+	runs := make([]*adminpb.SchedulerRun, 0, len(resp.Runs))
+	for _, run := range resp.Runs {
+		runs = append(runs, mapDomainSchedulerRun(run))
+	}
+
+	return &adminpb.ListSchedulerRunsResponse{
+		Runs:          runs,
+		NextPageToken: resp.NextPageToken,
+	}, nil
+}
+
+func (s *AdminServer) PauseScheduler(ctx context.Context, req *adminpb.PauseSchedulerRequest) (*adminpb.SchedulerStatus, error) {
+	if len(req.Reason) > maxPauseReasonLength {
+		return nil, status.Errorf(codes.InvalidArgument, "reason must be at most %d characters", maxPauseReasonLength)
+	}
+
+	st, err := s.service.PauseScheduler(ctx, &domain.PauseSchedulerRequest{Reason: req.Reason})
+	if err != nil {
+		return nil, mapDomainError(err)
+	}
+	return mapDomainSchedulerStatus(st), nil
+}
+
+func (s *AdminServer) ResumeScheduler(ctx context.Context, req *adminpb.ResumeSchedulerRequest) (*adminpb.SchedulerStatus, error) {
+	st, err := s.service.ResumeScheduler(ctx)
+	if err != nil {
+		return nil, mapDomainError(err)
+	}
+	return mapDomainSchedulerStatus(st), nil
+}
+
+func (s *AdminServer) TriggerRunNow(ctx context.Context, req *adminpb.TriggerRunNowRequest) (*adminpb.SchedulerRun, error) {
+	run, err := s.service.TriggerRunNow(ctx)
+	if err != nil {
+		return nil, mapDomainError(err)
+	}
+	return mapDomainSchedulerRun(run), nil
+}
+
+func mapDomainSchedulerStatus(st *domain.SchedulerStatus) *adminpb.SchedulerStatus {
+	resp := &adminpb.SchedulerStatus{
+		Holder:      st.Holder,
+		IsLeader:    st.IsLeader,
+		Enabled:     st.Enabled,
+		Running:     st.Running,
+		DryRun:      st.DryRun,
+		Paused:      st.Paused,
+		PauseReason: st.PauseReason,
+		Strategy:    st.Strategy,
+		Schedule:    st.Schedule,
+	}
+	if st.NextRunAt != nil {
+		resp.NextRunAt = timestamppb.New(*st.NextRunAt)
+	}
+	if st.PausedAt != nil {
+		resp.PausedAt = timestamppb.New(*st.PausedAt)
+	}
+	if st.LastRun != nil {
+		resp.LastRun = mapDomainSchedulerRun(st.LastRun)
+	}
+	return resp
+}
+
+func mapDomainSchedulerRun(run *domain.SchedulerRun) *adminpb.SchedulerRun {
+	resp := &adminpb.SchedulerRun{
+		Id:            run.ID,
+		Trigger:       mapDomainRunTrigger(run.Trigger),
+		Status:        mapDomainRunStatus(run.Status),
+		Holder:        run.Holder,
+		StartedAt:     timestamppb.New(run.StartedAt),
+		Candidates:    int32(run.Candidates),
+		Cancelled:     int32(run.Cancelled),
+		Skipped:       int32(run.Skipped),
+		Failed:        int32(run.Failed),
+		NotApplicable: int32(run.NotApplicable),
+		Parked:        int32(run.Parked),
+	}
+	if run.FinishedAt != nil {
+		resp.FinishedAt = timestamppb.New(*run.FinishedAt)
+	}
+
+	for _, o := range run.Outcomes {
+		resp.Outcomes = append(resp.Outcomes, &adminpb.RunOutcome{
+			OperationId: o.OperationID,
+			TxId:        o.TxID,
+			Result:      mapDomainCancelOutcome(o.Result),
+			Parked:      o.Parked,
+			AttemptedAt: timestamppb.New(o.AttemptedAt),
+		})
+	}
+	return resp
+}
+
+func mapDomainRunTrigger(t domain.SchedulerTrigger) adminpb.RunTrigger {
+	switch t {
+	case domain.SchedulerTriggerSchedule:
+		return adminpb.RunTrigger_RUN_TRIGGER_SCHEDULE
+	case domain.SchedulerTriggerManual:
+		return adminpb.RunTrigger_RUN_TRIGGER_MANUAL
+	default:
+		return adminpb.RunTrigger_RUN_TRIGGER_UNSPECIFIED
+	}
+}
+
+func mapDomainRunStatus(s domain.SchedulerRunStatus) adminpb.RunStatus {
+	switch s {
+	case domain.SchedulerRunRunning:
+		return adminpb.RunStatus_RUN_STATUS_RUNNING
+	case domain.SchedulerRunCompleted:
+		return adminpb.RunStatus_RUN_STATUS_COMPLETED
+	case domain.SchedulerRunInterrupted:
+		return adminpb.RunStatus_RUN_STATUS_INTERRUPTED
+	case domain.SchedulerRunAbandoned:
+		return adminpb.RunStatus_RUN_STATUS_ABANDONED
+	default:
+		return adminpb.RunStatus_RUN_STATUS_UNSPECIFIED
+	}
+}
+
+func mapDomainCancelOutcome(o domain.CancelOutcome) adminpb.CancelOutcome {
+	switch o {
+	case domain.CancelOutcomeCancelled:
+		return adminpb.CancelOutcome_CANCEL_OUTCOME_CANCELLED
+	case domain.CancelOutcomeSkipped:
+		return adminpb.CancelOutcome_CANCEL_OUTCOME_SKIPPED
+	case domain.CancelOutcomeFailed:
+		return adminpb.CancelOutcome_CANCEL_OUTCOME_FAILED
+	case domain.CancelOutcomeNotApplicable:
+		return adminpb.CancelOutcome_CANCEL_OUTCOME_NOT_APPLICABLE
+	default:
+		return adminpb.CancelOutcome_CANCEL_OUTCOME_UNSPECIFIED
+	}
+}
@@ -9,12 +9,54 @@ import (
 )
 
 func mapDomainError(err error) error {
+	if errors.Is(err, domain.ErrHoldNotFound) {
+		return status.Error(codes.NotFound, "hold not found")
+	}
+	if errors.Is(err, domain.ErrHoldClosed) {
+		return status.Error(codes.FailedPrecondition, "hold is already captured, released or expired")
+	}
+	if errors.Is(err, domain.ErrCaptureExceedsHold) {
+		return status.Error(codes.InvalidArgument, "capture amount exceeds hold")
+	}
+	if errors.Is(err, domain.ErrOperationNotCancellable) {
+		return status.Error(codes.FailedPrecondition, "only applied deposits and withdrawals can be canceled")
+	}
+	if errors.Is(err, domain.ErrInsufficientFundsToCancel) {
+		return status.Error(codes.FailedPrecondition, "insufficient funds to cancel operation")
+	}
+	if errors.Is(err, domain.ErrOperationNotFound) {
+		return status.Error(codes.NotFound, "operation not found")
+	}
 	if errors.Is(err, domain.ErrNotFound) {
 		return status.Error(codes.NotFound, "account not found")
 	}
 	if errors.Is(err, domain.ErrDuplicateTx) {
 		return status.Error(codes.AlreadyExists, "transaction already exists")
 	}
+	if errors.Is(err, domain.ErrInvalidPageToken) {
+		return status.Error(codes.InvalidArgument, "invalid page_token")
+	}
+	if errors.Is(err, domain.ErrIdempotencyConflict) {
+		return status.Error(codes.FailedPrecondition, "tx_id already used with a different payload")
+	}
+	if errors.Is(err, domain.ErrBalanceAtInFuture) {
+		return status.Error(codes.InvalidArgument, "at must not be in the future")
+	}
+	if errors.Is(err, domain.ErrWatchInterrupted) {
+		return status.Error(codes.Unavailable, "balance watch interrupted, resubscribe")
+	}
+	if errors.Is(err, domain.ErrNotSchedulerLeader) {
+		return status.Error(codes.FailedPrecondition, "this replica is not the scheduler leader, retry on the leader")
+	}
+	if errors.Is(err, domain.ErrSchedulerPaused) {
+		return status.Error(codes.FailedPrecondition, "scheduler is paused")
+	}
+	if errors.Is(err, domain.ErrSchedulerDryRun) {
+		return status.Error(codes.FailedPrecondition, "scheduler is in dry-run mode")
+	}
+	if errors.Is(err, domain.ErrSchedulerBusy) {
+		return status.Error(codes.Aborted, "cancellation cycle already running")
+	}
 	if errors.Is(err, domain.ErrNegativeBalance) {
 		return status.Error(codes.InvalidArgument, "insufficient balance")
 	}
@@ -0,0 +1,63 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+)
+
+// Probes answer the liveness and readiness checks of an orchestrator.
+type Probes interface {
+	Live() error
+	Ready() error
+}
+
+// NewHTTPServer serves the default Prometheus registry on /metrics and the
+// probes on /livez and /readyz, which answer 503 while the probe fails.
+func NewHTTPServer(port string, probes Probes) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.Handle("/livez", probeHandler(probes.Live))
+	mux.Handle("/readyz", probeHandler(probes.Ready))
+
+	return &http.Server{
+		Addr:              ":" + port,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+}
+
+// ServeHTTP accepts connections for s until it is shut down.
+func ServeHTTP(s *http.Server) error {
+	zap.L().Info("http server started", zap.String("addr", s.Addr))
+	if err := s.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// StopHTTP lets in-flight requests finish until ctx is done, then closes them.
+func StopHTTP(ctx context.Context, s *http.Server) {
+	if err := s.Shutdown(ctx); err != nil {
+		zap.L().Warn("http server did not stop in time, closing open connections", zap.Error(err))
+		_ = s.Close()
+		return
+	}
+	zap.L().Info("http server stopped")
+}
+
+func probeHandler(probe func() error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if err := probe(); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(err.Error() + "\n"))
+			return
+		}
+		_, _ = w.Write([]byte("ok\n"))
+	})
+}
@@ -0,0 +1,69 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestProbeHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		probe    func() error
+		expected int
+	}{
+		{name: "passing", probe: func() error { return nil }, expected: http.StatusOK},
+		{name: "failing", probe: func() error { return errors.New("database unavailable") }, expected: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			probeHandler(tt.probe).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if rec.Code != tt.expected {
+				t.Errorf("status = %d, want %d", rec.Code, tt.expected)
+			}
+		})
+	}
+}
+
+type fakeProbes struct{ live, ready error }
+
+func (p fakeProbes) Live() error  { return p.live }
+func (p fakeProbes) Ready() error { return p.ready }
+
+func TestHTTPServer(t *testing.T) {
+	server := NewHTTPServer("0", fakeProbes{ready: errors.New("shutting down")})
+
+	for path, expected := range map[string]int{
+		"/livez":   http.StatusOK,
+		"/readyz":  http.StatusServiceUnavailable,
+		"/metrics": http.StatusOK,
+	} {
+		rec := httptest.NewRecorder()
+		server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != expected {
+			t.Errorf("GET %s status = %d, want %d", path, rec.Code, expected)
+		}
+	}
+
+	served := make(chan error, 1)
+	go func() { served <- ServeHTTP(server) }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	StopHTTP(ctx, server)
+
+	// a shut down server is not a serving error
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("ServeHTTP() after StopHTTP = %v, want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ServeHTTP() did not return after StopHTTP")
+	}
+}
@@ -0,0 +1,128 @@
+package transport
+
+import (
+	"context"
+	"time"
+
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/logger"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDHeader carries the request ID in both directions.
+const RequestIDHeader = "x-request-id"
+
+// maxRequestIDLen bounds a caller's request ID; longer ones are replaced.
+const maxRequestIDLen = 128
+
+// serverStream lets an interceptor replace the context of a stream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// requestID is the caller's x-request-id, or a new one.
+func requestID(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(RequestIDHeader); len(ids) > 0 && ids[0] != "" && len(ids[0]) <= maxRequestIDLen {
+			return ids[0]
+		}
+	}
+	return uuid.NewString()
+}
+
+// withRequestLogger attaches a logger tagged with the request to ctx.
+func withRequestLogger(ctx context.Context, id string, method string) context.Context {
+	log := logger.FromContext(ctx).With(
+		zap.String("request_id", id),
+		zap.String("method", method),
+	)
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		log = log.With(zap.String("trace_id", sc.TraceID().String()))
+	}
+	return logger.WithContext(ctx, log)
+}
+
+func requestIDUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	id := requestID(ctx)
+	if err := grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, id)); err != nil {
+		logger.FromContext(ctx).Warn("failed to set request id header", zap.Error(err))
+	}
+	return handler(withRequestLogger(ctx, id, info.FullMethod), req)
+}
+
+func requestIDStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx := ss.Context()
+	id := requestID(ctx)
+	if err := ss.SetHeader(metadata.Pairs(RequestIDHeader, id)); err != nil {
+		logger.FromContext(ctx).Warn("failed to set request id header", zap.Error(err))
+	}
+	return handler(srv, &serverStream{ServerStream: ss, ctx: withRequestLogger(ctx, id, info.FullMethod)})
+}
+
+// logRPC writes the access log line of a finished RPC.
+func logRPC(ctx context.Context, start time.Time, err error) {
+	code := status.Code(err)
+
+	level := zapcore.InfoLevel
+	switch code {
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable, codes.Unimplemented:
+		level = zapcore.ErrorLevel
+	}
+
+	logger.FromContext(ctx).Log(level, "rpc finished",
+		zap.String("code", code.String()),
+		zap.Duration("duration", time.Since(start)),
+		zap.Error(err))
+}
+
+func accessLogUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	logRPC(ctx, start, err)
+	return resp, err
+}
+
+func accessLogStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	logRPC(ss.Context(), start, err)
+	return err
+}
+
+// recovered turns a panic into codes.Internal. Panics in goroutines a
+// handler starts are not caught.
+func recovered(ctx context.Context, p any) error {
+	logger.FromContext(ctx).Error("panic in rpc handler",
+		zap.Any("panic", p),
+		zap.Stack("stack"))
+	return status.Error(codes.Internal, "internal error")
+}
+
+func recoveryUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			resp, err = nil, recovered(ctx, p)
+		}
+	}()
+	return handler(ctx, req)
+}
+
+func recoveryStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = recovered(ss.Context(), p)
+		}
+	}()
+	return handler(srv, ss)
+}
@@ -0,0 +1,169 @@
+package transport
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/logger"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestRecoveryUnaryInterceptor(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/balance.BalanceService/Test"}
+	panics := func(ctx context.Context, req any) (any, error) { panic("boom") }
+
+	resp, err := recoveryUnaryInterceptor(context.Background(), nil, info, panics)
+	if resp != nil || status.Code(err) != codes.Internal {
+		t.Errorf("interceptor = %v, %v, want nil, Internal", resp, err)
+	}
+}
+
+func TestRequestIDUnaryInterceptor(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	ctx := logger.WithContext(context.Background(), zap.New(core))
+	info := &grpc.UnaryServerInfo{FullMethod: "/balance.BalanceService/Test"}
+
+	handler := func(ctx context.Context, req any) (any, error) {
+		logger.FromContext(ctx).Info("handled")
+		return nil, nil
+	}
+
+	// propagated from the caller
+	incoming := metadata.NewIncomingContext(ctx, metadata.Pairs(RequestIDHeader, "req-1"))
+	if _, err := requestIDUnaryInterceptor(incoming, nil, info, handler); err != nil {
+		t.Fatalf("interceptor error = %v", err)
+	}
+	// outside a real server the response header cannot be set, which is logged too
+	entries := logs.FilterMessage("handled").All()
+	if len(entries) != 1 || entries[0].ContextMap()["request_id"] != "req-1" {
+		t.Errorf("log entries = %v, want one with request_id req-1", entries)
+	}
+
+	// generated when missing
+	if _, err := requestIDUnaryInterceptor(ctx, nil, info, handler); err != nil {
+		t.Fatalf("interceptor error = %v", err)
+	}
+	entries = logs.FilterMessage("handled").All()[1:]
+	if len(entries) != 1 || entries[0].ContextMap()["request_id"] == "" {
+		t.Errorf("log entries = %v, want one with a generated request_id", entries)
+	}
+	if entries[0].ContextMap()["method"] != info.FullMethod {
+		t.Errorf("method = %v, want %v", entries[0].ContextMap()["method"], info.FullMethod)
+	}
+}
+
+// fakeServerStream is a grpc.ServerStream that only carries a context and
+// collects the header.
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	header metadata.MD
+}
+
+func (s *fakeServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeServerStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func TestAccessLogUnaryInterceptor(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	ctx := logger.WithContext(context.Background(), zap.New(core))
+	info := &grpc.UnaryServerInfo{FullMethod: "/balance.BalanceService/Test"}
+
+	tests := []struct {
+		err   error
+		code  string
+		level zapcore.Level
+	}{
+		{nil, "OK", zap.InfoLevel},
+		{status.Error(codes.NotFound, "not found"), "NotFound", zap.InfoLevel},
+		{status.Error(codes.Internal, "internal error"), "Internal", zap.ErrorLevel},
+	}
+	for _, tt := range tests {
+		handler := func(ctx context.Context, req any) (any, error) { return nil, tt.err }
+		if _, err := accessLogUnaryInterceptor(ctx, nil, info, handler); err != tt.err {
+			t.Fatalf("interceptor error = %v, want %v", err, tt.err)
+		}
+
+		entries := logs.TakeAll()
+		if len(entries) != 1 || entries[0].Message != "rpc finished" {
+			t.Fatalf("log entries = %v, want one rpc finished", entries)
+		}
+		fields := entries[0].ContextMap()
+		if fields["code"] != tt.code || entries[0].Level != tt.level {
+			t.Errorf("code, level = %v, %v, want %v, %v", fields["code"], entries[0].Level, tt.code, tt.level)
+		}
+		if _, ok := fields["duration"]; !ok {
+			t.Errorf("log fields = %v, want a duration", fields)
+		}
+	}
+}
+
+func TestRequestIDStreamInterceptor(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	ctx := logger.WithContext(context.Background(), zap.New(core))
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(RequestIDHeader, "req-1"))
+	info := &grpc.StreamServerInfo{FullMethod: "/balance.BalanceService/Watch"}
+	ss := &fakeServerStream{ctx: ctx}
+
+	handler := func(srv any, stream grpc.ServerStream) error {
+		logger.FromContext(stream.Context()).Info("handled")
+		return nil
+	}
+
+	if err := requestIDStreamInterceptor(nil, ss, info, handler); err != nil {
+		t.Fatalf("interceptor error = %v", err)
+	}
+	if got := ss.header.Get(RequestIDHeader); len(got) != 1 || got[0] != "req-1" {
+		t.Errorf("response header = %v, want req-1", got)
+	}
+	entries := logs.FilterMessage("handled").All()
+	if len(entries) != 1 {
+		t.Fatalf("log entries = %v, want one", entries)
+	}
+	fields := entries[0].ContextMap()
+	if fields["request_id"] != "req-1" || fields["method"] != info.FullMethod {
+		t.Errorf("log fields = %v, want request_id req-1 and method %s", fields, info.FullMethod)
+	}
+}
+
+func TestRecoveryStreamInterceptor(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	ctx := logger.WithContext(context.Background(), zap.New(core))
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(RequestIDHeader, "req-1"))
+	info := &grpc.StreamServerInfo{FullMethod: "/balance.BalanceService/Watch"}
+
+	panics := func(srv any, stream grpc.ServerStream) error { panic("boom") }
+	// chained the way the server does: the request ID is set before recovery
+	handler := func(srv any, stream grpc.ServerStream) error {
+		return recoveryStreamInterceptor(srv, stream, info, panics)
+	}
+
+	err := requestIDStreamInterceptor(nil, &fakeServerStream{ctx: ctx}, info, handler)
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("interceptor error = %v, want Internal", err)
+	}
+
+	entries := logs.FilterMessage("panic in rpc handler").All()
+	if len(entries) != 1 || entries[0].Level != zap.ErrorLevel {
+		t.Fatalf("log entries = %v, want one error", entries)
+	}
+	fields := entries[0].ContextMap()
+	if fields["request_id"] != "req-1" || fields["panic"] != "boom" {
+		t.Errorf("log fields = %v, want request_id req-1 and panic boom", fields)
+	}
+	if stack, _ := fields["stack"].(string); !strings.Contains(stack, "TestRecoveryStreamInterceptor") {
+		t.Errorf("stack = %q, want the panicking handler", stack)
+	}
+}
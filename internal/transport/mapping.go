@@ -3,8 +3,10 @@ package transport
 import (
 	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/domain"
 	pb "github.com/MaksimPozharskiy/grpc-balance-processor/proto/balance"
+	"github.com/google/uuid"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 func mapProtoSource(s pb.Source) (domain.Source, error) {
@@ -26,11 +28,107 @@ func mapProtoState(s pb.State) (domain.State, error) {
 		return domain.StateDeposit, nil
 	case pb.State_STATE_WITHDRAW:
 		return domain.StateWithdraw, nil
+	case pb.State_STATE_HOLD:
+		return domain.StateHold, nil
+	case pb.State_STATE_CAPTURE:
+		return domain.StateCapture, nil
+	case pb.State_STATE_RELEASE:
+		return domain.StateRelease, nil
 	default:
 		return "", status.Error(codes.InvalidArgument, "invalid state value")
 	}
 }
 
+func mapProtoOperationFilter(req *pb.ListOperationsRequest, accountID uuid.UUID) (domain.OperationFilter, error) {
+	filter := domain.OperationFilter{
+		AccountID: accountID,
+		Applied:   req.Applied,
+		Canceled:  req.Canceled,
+	}
+
+	if req.Source != pb.Source_SOURCE_UNSPECIFIED {
+		source, err := mapProtoSource(req.Source)
+		if err != nil {
+			return filter, err
+		}
+		filter.Source = &source
+	}
+
+	if req.State != pb.State_STATE_UNSPECIFIED {
+		state, err := mapProtoState(req.State)
+		if err != nil {
+			return filter, err
+		}
+		filter.State = &state
+	}
+
+	if req.CreatedFrom != nil {
+		from := req.CreatedFrom.AsTime()
+		filter.CreatedFrom = &from
+	}
+
+	if req.CreatedTo != nil {
+		to := req.CreatedTo.AsTime()
+		filter.CreatedTo = &to
+	}
+
+	return filter, nil
+}
+
+func mapDomainSource(s domain.Source) pb.Source {
+	switch s {
+	case domain.SourceGame:
+		return pb.Source_SOURCE_GAME
+	case domain.SourcePayment:
+		return pb.Source_SOURCE_PAYMENT
+	case domain.SourceService:
+		return pb.Source_SOURCE_SERVICE
+	default:
+		return pb.Source_SOURCE_UNSPECIFIED
+	}
+}
+
+func mapDomainState(s domain.State) pb.State {
+	switch s {
+	case domain.StateDeposit:
+		return pb.State_STATE_DEPOSIT
+	case domain.StateWithdraw:
+		return pb.State_STATE_WITHDRAW
+	case domain.StateHold:
+		return pb.State_STATE_HOLD
+	case domain.StateCapture:
+		return pb.State_STATE_CAPTURE
+	case domain.StateRelease:
+		return pb.State_STATE_RELEASE
+	default:
+		return pb.State_STATE_UNSPECIFIED
+	}
+}
+
+func mapDomainOperation(op *domain.Operation) *pb.Operation {
+	if op == nil {
+		return nil
+	}
+
+	res := &pb.Operation{
+		Id:         op.ID,
+		TxId:       op.TxID,
+		AccountId:  op.AccountID.String(),
+		Source:     mapDomainSource(op.Source),
+		State:      mapDomainState(op.State),
+		Amount:     op.Amount.String(),
+		CreatedAt:  timestamppb.New(op.CreatedAt),
+		Applied:    op.Applied,
+		CancelNote: op.CancelNote,
+		TransferId: op.TransferID,
+	}
+	if op.CanceledAt != nil {
+		res.CanceledAt = timestamppb.New(*op.CanceledAt)
+	}
+
+	return res
+}
+
 func mapDomainStatus(s domain.ProcessStatus) pb.Status {
 	switch s {
 	case domain.StatusOK:
@@ -43,3 +141,50 @@ func mapDomainStatus(s domain.ProcessStatus) pb.Status {
 		return pb.Status_STATUS_OK
 	}
 }
+
+func mapDomainProcessResponse(resp *domain.ProcessResponse) *pb.ProcessResponse {
+	return &pb.ProcessResponse{
+		TxId:        resp.TxID,
+		Status:      mapDomainStatus(resp.Status),
+		Balance:     resp.Balance.String(),
+		ProcessedAt: timestamppb.New(resp.Timestamp),
+		Replayed:    resp.Replayed,
+	}
+}
+
+func mapProtoBatchMode(m pb.BatchMode) (domain.BatchMode, error) {
+	switch m {
+	case pb.BatchMode_BATCH_MODE_ALL_OR_NOTHING:
+		return domain.BatchModeAllOrNothing, nil
+	case pb.BatchMode_BATCH_MODE_BEST_EFFORT:
+		return domain.BatchModeBestEffort, nil
+	default:
+		return 0, status.Error(codes.InvalidArgument, "invalid mode value")
+	}
+}
+
+func mapDomainHoldResponse(resp *domain.HoldResponse) *pb.HoldResponse {
+	res := &pb.HoldResponse{
+		TxId:        resp.TxID,
+		HoldTxId:    resp.HoldTxID,
+		Status:      mapDomainStatus(resp.Status),
+		Balance:     resp.Balance.String(),
+		Available:   resp.Available.String(),
+		ProcessedAt: timestamppb.New(resp.Timestamp),
+		Replayed:    resp.Replayed,
+	}
+	if !resp.ExpiresAt.IsZero() {
+		res.ExpiresAt = timestamppb.New(resp.ExpiresAt)
+	}
+	return res
+}
+
+func mapDomainBalanceEvent(ev *domain.BalanceEvent) *pb.BalanceEvent {
+	return &pb.BalanceEvent{
+		AccountId: ev.AccountID.String(),
+		TxId:      ev.TxID,
+		Balance:   ev.Balance.String(),
+		Available: ev.Available.String(),
+		UpdatedAt: timestamppb.New(ev.UpdatedAt),
+	}
+}
@@ -0,0 +1,58 @@
+package transport
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestMetricsUnaryInterceptor(t *testing.T) {
+	const method = "/balance.BalanceService/Test"
+	info := &grpc.UnaryServerInfo{FullMethod: method}
+
+	ok := func(ctx context.Context, req any) (any, error) { return "ok", nil }
+	failed := func(ctx context.Context, req any) (any, error) {
+		return nil, status.Error(codes.NotFound, "not found")
+	}
+
+	// the counters are global and other runs of the test add to them
+	okRequests := rpcRequests.WithLabelValues(method, codes.OK.String())
+	notFoundRequests := rpcRequests.WithLabelValues(method, codes.NotFound.String())
+	okBefore := testutil.ToFloat64(okRequests)
+	notFoundBefore := testutil.ToFloat64(notFoundRequests)
+	durationsBefore := sampleCount(t, method)
+
+	if _, err := metricsUnaryInterceptor(context.Background(), nil, info, ok); err != nil {
+		t.Fatalf("interceptor error = %v", err)
+	}
+	if _, err := metricsUnaryInterceptor(context.Background(), nil, info, failed); status.Code(err) != codes.NotFound {
+		t.Fatalf("interceptor error = %v, want NotFound", err)
+	}
+
+	if got := testutil.ToFloat64(okRequests) - okBefore; got != 1 {
+		t.Errorf("requests with OK increased by %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(notFoundRequests) - notFoundBefore; got != 1 {
+		t.Errorf("requests with NotFound increased by %v, want 1", got)
+	}
+	if got := sampleCount(t, method) - durationsBefore; got != 2 {
+		t.Errorf("duration observations increased by %v, want 2", got)
+	}
+}
+
+// sampleCount is the number of durations observed for method.
+func sampleCount(t *testing.T, method string) uint64 {
+	t.Helper()
+
+	var m dto.Metric
+	if err := rpcDuration.WithLabelValues(method).(prometheus.Metric).Write(&m); err != nil {
+		t.Fatalf("write duration metric: %v", err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
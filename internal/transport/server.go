@@ -2,15 +2,21 @@ package transport
 
 import (
 	"context"
+	"errors"
 	"net"
 
 	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/domain"
+	adminpb "github.com/MaksimPozharskiy/grpc-balance-processor/proto/admin"
 	pb "github.com/MaksimPozharskiy/grpc-balance-processor/proto/balance"
+	"github.com/shopspring/decimal"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/health"
 	"google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -26,6 +32,67 @@ func NewServer(service domain.BalanceService) *Server {
 }
 
 func (s *Server) Process(ctx context.Context, req *pb.ProcessRequest) (*pb.ProcessResponse, error) {
+	domainReq, err := parseProcessRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.service.Process(ctx, domainReq)
+	if err != nil {
+		return nil, mapDomainError(err)
+	}
+
+	return mapDomainProcessResponse(resp), nil
+}
+
+func (s *Server) BatchProcess(ctx context.Context, req *pb.BatchProcessRequest) (*pb.BatchProcessResponse, error) {
+	mode, err := validateBatchProcessRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*domain.ProcessRequest, 0, len(req.Items))
+	for i, item := range req.Items {
+		domainItem, err := parseProcessRequest(item)
+		if err != nil {
+			st := status.Convert(err)
+			return nil, status.Errorf(st.Code(), "items[%d]: %s", i, st.Message())
+		}
+		items = append(items, domainItem)
+	}
+
+	resp, err := s.service.BatchProcess(ctx, &domain.BatchProcessRequest{Mode: mode, Items: items})
+	if err != nil {
+		var itemErr *domain.BatchItemError
+		if errors.As(err, &itemErr) {
+			st := status.Convert(mapDomainError(itemErr.Err))
+			return nil, status.Errorf(st.Code(), "batch aborted at items[%d] (tx_id %s): %s", itemErr.Index, itemErr.TxID, st.Message())
+		}
+		return nil, mapDomainError(err)
+	}
+
+	results := make([]*pb.BatchItemResult, 0, len(resp.Results))
+	for _, r := range resp.Results {
+		if r.Err != nil {
+			st := status.Convert(mapDomainError(r.Err))
+			results = append(results, &pb.BatchItemResult{
+				Result: &pb.BatchItemResult_Error{Error: &pb.BatchItemError{
+					Code:    int32(st.Code()),
+					Message: st.Message(),
+				}},
+			})
+			continue
+		}
+		results = append(results, &pb.BatchItemResult{
+			Result: &pb.BatchItemResult_Response{Response: mapDomainProcessResponse(r.Response)},
+		})
+	}
+
+	return &pb.BatchProcessResponse{Results: results}, nil
+}
+
+// parseProcessRequest validates a ProcessRequest and converts it to the domain type.
+func parseProcessRequest(req *pb.ProcessRequest) (*domain.ProcessRequest, error) {
 	if err := validateProcessRequest(req); err != nil {
 		return nil, err
 	}
@@ -54,24 +121,68 @@ func (s *Server) Process(ctx context.Context, req *pb.ProcessRequest) (*pb.Proce
 		return nil, err
 	}
 
-	domainReq := &domain.ProcessRequest{
+	return &domain.ProcessRequest{
 		AccountID: accountID,
 		Source:    source,
 		State:     state,
 		Amount:    amount,
 		TxID:      req.TxId,
+	}, nil
+}
+
+func (s *Server) Transfer(ctx context.Context, req *pb.TransferRequest) (*pb.TransferResponse, error) {
+	if err := validateTransferRequest(req); err != nil {
+		return nil, err
 	}
 
-	resp, err := s.service.Process(ctx, domainReq)
+	fromAccountID, err := validateAndParseAccountID(req.FromAccountId)
+	if err != nil {
+		return nil, err
+	}
+
+	toAccountID, err := validateAndParseAccountID(req.ToAccountId)
+	if err != nil {
+		return nil, err
+	}
+
+	if fromAccountID == toAccountID {
+		return nil, status.Error(codes.InvalidArgument, "from_account_id and to_account_id must differ")
+	}
+
+	amount, err := validateAndParseAmount(req.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateTxID(req.TxId); err != nil {
+		return nil, err
+	}
+
+	source, err := mapProtoSource(req.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	domainReq := &domain.TransferRequest{
+		FromAccountID: fromAccountID,
+		ToAccountID:   toAccountID,
+		Source:        source,
+		Amount:        amount,
+		TxID:          req.TxId,
+	}
+
+	resp, err := s.service.Transfer(ctx, domainReq)
 	if err != nil {
 		return nil, mapDomainError(err)
 	}
 
-	return &pb.ProcessResponse{
+	return &pb.TransferResponse{
 		TxId:        resp.TxID,
 		Status:      mapDomainStatus(resp.Status),
-		Balance:     resp.Balance.String(),
+		FromBalance: resp.FromBalance.String(),
+		ToBalance:   resp.ToBalance.String(),
 		ProcessedAt: timestamppb.New(resp.Timestamp),
+		Replayed:    resp.Replayed,
 	}, nil
 }
 
@@ -93,29 +204,310 @@ func (s *Server) GetBalance(ctx context.Context, req *pb.GetBalanceRequest) (*pb
 	return &pb.GetBalanceResponse{
 		Balance:   resp.Balance.String(),
 		UpdatedAt: timestamppb.New(resp.UpdatedAt),
+		Available: resp.Available.String(),
 	}, nil
 }
 
-func NewGRPCServer(service domain.BalanceService) *grpc.Server {
-	s := grpc.NewServer()
+func (s *Server) GetBalanceAt(ctx context.Context, req *pb.GetBalanceAtRequest) (*pb.GetBalanceAtResponse, error) {
+	if err := validateGetBalanceAtRequest(req); err != nil {
+		return nil, err
+	}
+
+	accountID, err := validateAndParseAccountID(req.AccountId)
+	if err != nil {
+		return nil, err
+	}
+
+	domainReq := &domain.GetBalanceAtRequest{
+		AccountID: accountID,
+		At:        req.At.AsTime(),
+	}
+
+	resp, err := s.service.GetBalanceAt(ctx, domainReq)
+	if err != nil {
+		return nil, mapDomainError(err)
+	}
+
+	return &pb.GetBalanceAtResponse{
+		Balance: resp.Balance.String(),
+		At:      timestamppb.New(resp.At),
+	}, nil
+}
+
+func (s *Server) WatchBalance(req *pb.WatchBalanceRequest, stream pb.BalanceService_WatchBalanceServer) error {
+	accountID, err := validateAndParseAccountID(req.AccountId)
+	if err != nil {
+		return err
+	}
+
+	domainReq := &domain.WatchBalanceRequest{
+		AccountID: accountID,
+	}
+
+	err = s.service.WatchBalance(stream.Context(), domainReq, func(ev *domain.BalanceEvent) error {
+		return stream.Send(mapDomainBalanceEvent(ev))
+	})
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return err // failed Send already carries a status
+		}
+		return mapDomainError(err)
+	}
+
+	return nil
+}
+
+func (s *Server) Reserve(ctx context.Context, req *pb.ReserveRequest) (*pb.HoldResponse, error) {
+	if err := validateReserveRequest(req); err != nil {
+		return nil, err
+	}
+
+	accountID, err := validateAndParseAccountID(req.AccountId)
+	if err != nil {
+		return nil, err
+	}
+
+	amount, err := validateAndParseAmount(req.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateTxID(req.TxId); err != nil {
+		return nil, err
+	}
+
+	source, err := mapProtoSource(req.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	domainReq := &domain.ReserveRequest{
+		AccountID: accountID,
+		Source:    source,
+		Amount:    amount,
+		TxID:      req.TxId,
+	}
+
+	resp, err := s.service.Reserve(ctx, domainReq)
+	if err != nil {
+		return nil, mapDomainError(err)
+	}
+
+	return mapDomainHoldResponse(resp), nil
+}
+
+func (s *Server) Capture(ctx context.Context, req *pb.CaptureRequest) (*pb.HoldResponse, error) {
+	if err := validateHoldTxID(req.HoldTxId); err != nil {
+		return nil, err
+	}
+
+	if err := validateTxID(req.TxId); err != nil {
+		return nil, err
+	}
+
+	// empty amount captures the whole hold
+	amount := decimal.Zero
+	if req.Amount != "" {
+		parsed, err := validateAndParseAmount(req.Amount)
+		if err != nil {
+			return nil, err
+		}
+		amount = parsed
+	}
+
+	domainReq := &domain.CaptureRequest{
+		HoldTxID: req.HoldTxId,
+		Amount:   amount,
+		TxID:     req.TxId,
+	}
+
+	resp, err := s.service.Capture(ctx, domainReq)
+	if err != nil {
+		return nil, mapDomainError(err)
+	}
+
+	return mapDomainHoldResponse(resp), nil
+}
+
+func (s *Server) Release(ctx context.Context, req *pb.ReleaseRequest) (*pb.HoldResponse, error) {
+	if err := validateHoldTxID(req.HoldTxId); err != nil {
+		return nil, err
+	}
+
+	if err := validateTxID(req.TxId); err != nil {
+		return nil, err
+	}
+
+	domainReq := &domain.ReleaseRequest{
+		HoldTxID: req.HoldTxId,
+		TxID:     req.TxId,
+	}
+
+	resp, err := s.service.Release(ctx, domainReq)
+	if err != nil {
+		return nil, mapDomainError(err)
+	}
+
+	return mapDomainHoldResponse(resp), nil
+}
+
+func (s *Server) GetOperation(ctx context.Context, req *pb.GetOperationRequest) (*pb.GetOperationResponse, error) {
+	if err := validateLookupTxID(req.TxId); err != nil {
+		return nil, err
+	}
+
+	domainReq := &domain.GetOperationRequest{
+		TxID: req.TxId,
+	}
+
+	resp, err := s.service.GetOperation(ctx, domainReq)
+	if err != nil {
+		return nil, mapDomainError(err)
+	}
+
+	return &pb.GetOperationResponse{
+		Operation:    mapDomainOperation(resp.Operation),
+		Compensation: mapDomainOperation(resp.Compensation),
+	}, nil
+}
+
+func (s *Server) CancelOperation(ctx context.Context, req *pb.CancelOperationRequest) (*pb.CancelOperationResponse, error) {
+	if err := validateCancelOperationRequest(req); err != nil {
+		return nil, err
+	}
+
+	domainReq := &domain.CancelOperationRequest{
+		TxID:   req.TxId,
+		Reason: req.Reason,
+	}
+
+	resp, err := s.service.CancelOperation(ctx, domainReq)
+	if err != nil {
+		return nil, mapDomainError(err)
+	}
+
+	return &pb.CancelOperationResponse{
+		Operation:    mapDomainOperation(resp.Operation),
+		Compensation: mapDomainOperation(resp.Compensation),
+		Replayed:     resp.Replayed,
+	}, nil
+}
+
+func (s *Server) ListOperations(ctx context.Context, req *pb.ListOperationsRequest) (*pb.ListOperationsResponse, error) {
+	accountID, err := validateAndParseAccountID(req.AccountId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateListOperationsRequest(req); err != nil {
+		return nil, err
+	}
+
+	filter, err := mapProtoOperationFilter(req, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	domainReq := &domain.ListOperationsRequest{
+		Filter:    filter,
+		PageSize:  int(req.PageSize),
+		PageToken: req.PageToken,
+	}
+
+	resp, err := s.service.ListOperations(ctx, domainReq)
+	if err != nil {
+		return nil, mapDomainError(err)
+	}
+
+	ops := make([]*pb.Operation, 0, len(resp.Operations))
+	for _, op := range resp.Operations {
+		ops = append(ops, mapDomainOperation(op))
+	}
+
+	return &pb.ListOperationsResponse{
+		Operations:    ops,
+		NextPageToken: resp.NextPageToken,
+	}, nil
+}
+
+// DatabaseServices are the services registered by NewGRPCServer; none of
+// them can serve while the database is unreachable.
+var DatabaseServices = []string{
+	pb.BalanceService_ServiceDesc.ServiceName,
+}
+
+// serverOptions are shared by the public and the admin server.
+func serverOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		// continues the caller's trace from the request metadata
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		// recovery runs innermost, so a panic is logged and counted as Internal
+		grpc.ChainUnaryInterceptor(
+			requestIDUnaryInterceptor,
+			accessLogUnaryInterceptor,
+			metricsUnaryInterceptor,
+			recoveryUnaryInterceptor,
+		),
+		grpc.ChainStreamInterceptor(
+			requestIDStreamInterceptor,
+			accessLogStreamInterceptor,
+			metricsStreamInterceptor,
+			recoveryStreamInterceptor,
+		),
+	}
+}
+
+// NewGRPCServer serves the balance service and reports health through
+// healthServer, whose statuses the caller keeps up to date.
+func NewGRPCServer(service domain.BalanceService, healthServer *health.Server) *grpc.Server {
+	s := grpc.NewServer(serverOptions()...)
 
 	pb.RegisterBalanceServiceServer(s, NewServer(service))
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
 
-	healthService := health.NewServer()
-	grpc_health_v1.RegisterHealthServer(s, healthService)
-	healthService.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	reflection.Register(s)
+
+	return s
+}
+
+// NewAdminGRPCServer serves the scheduler admin service. It has no
+// authentication of its own: it is meant for a listener that only operators
+// can reach, never the public port.
+func NewAdminGRPCServer(admin domain.SchedulerAdminService) *grpc.Server {
+	s := grpc.NewServer(serverOptions()...)
+
+	adminpb.RegisterSchedulerAdminServiceServer(s, NewAdminServer(admin))
 
 	reflection.Register(s)
 
 	return s
 }
 
-func Serve(s *grpc.Server, port string) error {
-	lis, err := net.Listen("tcp", ":"+port)
+// Serve accepts connections for s on addr, a host:port or :port.
+func Serve(s *grpc.Server, addr string) error {
+	lis, err := net.Listen("tcp", addr)
 	if err != nil {
 		return err
 	}
 
-	zap.L().Info("gRPC server started", zap.String("port", port))
+	zap.L().Info("gRPC server started", zap.String("addr", addr))
 	return s.Serve(lis)
 }
+
+// Stop lets in-flight RPCs finish until ctx is done, then cuts them off.
+func Stop(ctx context.Context, s *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		zap.L().Info("gRPC server stopped")
+	case <-ctx.Done():
+		zap.L().Warn("gRPC server did not stop in time, closing open RPCs")
+		s.Stop()
+		<-stopped
+	}
+}
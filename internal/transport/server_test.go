@@ -0,0 +1,24 @@
+package transport
+
+import (
+	"testing"
+
+	adminpb "github.com/MaksimPozharskiy/grpc-balance-processor/proto/admin"
+	pb "github.com/MaksimPozharskiy/grpc-balance-processor/proto/balance"
+	"google.golang.org/grpc/health"
+)
+
+func TestAdminServiceNotOnPublicServer(t *testing.T) {
+	public := NewGRPCServer(nil, health.NewServer()).GetServiceInfo()
+	if _, ok := public[pb.BalanceService_ServiceDesc.ServiceName]; !ok {
+		t.Errorf("public server does not serve %s", pb.BalanceService_ServiceDesc.ServiceName)
+	}
+	if _, ok := public[adminpb.SchedulerAdminService_ServiceDesc.ServiceName]; ok {
+		t.Errorf("public server serves %s", adminpb.SchedulerAdminService_ServiceDesc.ServiceName)
+	}
+
+	admin := NewAdminGRPCServer(nil).GetServiceInfo()
+	if _, ok := admin[adminpb.SchedulerAdminService_ServiceDesc.ServiceName]; !ok {
+		t.Errorf("admin server does not serve %s", adminpb.SchedulerAdminService_ServiceDesc.ServiceName)
+	}
+}
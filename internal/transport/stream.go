@@ -0,0 +1,126 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"io"
+	"sync"
+
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/domain"
+	pb "github.com/MaksimPozharskiy/grpc-balance-processor/proto/balance"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// streamWorkers bounds the number of requests of one stream processed concurrently
+	streamWorkers = 8
+	// streamQueueSize bounds the requests buffered per worker before Recv stops
+	streamQueueSize = 32
+)
+
+// ProcessStream applies requests with Process semantics. Requests for the same
+// account go to the same worker and are applied in stream order. When workers
+// fall behind their queues fill up and the server stops reading, which pushes
+// back on the client through gRPC flow control.
+func (s *Server) ProcessStream(stream pb.BalanceService_ProcessStreamServer) error {
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	results := make(chan *pb.ProcessStreamResponse, streamQueueSize)
+	sendDone := make(chan error, 1)
+	go func() {
+		var sendErr error
+		for res := range results {
+			if sendErr != nil {
+				continue // keep draining so workers never block
+			}
+			if sendErr = stream.Send(res); sendErr != nil {
+				cancel()
+			}
+		}
+		sendDone <- sendErr
+	}()
+
+	queues := make([]chan *domain.ProcessRequest, streamWorkers)
+	var wg sync.WaitGroup
+	for i := range queues {
+		queues[i] = make(chan *domain.ProcessRequest, streamQueueSize)
+		wg.Add(1)
+		go func(queue <-chan *domain.ProcessRequest) {
+			defer wg.Done()
+			for req := range queue {
+				results <- s.processStreamItem(ctx, req)
+			}
+		}(queues[i])
+	}
+
+	recvErr := receiveStream(ctx, stream, queues, results)
+
+	for _, queue := range queues {
+		close(queue)
+	}
+	wg.Wait()
+	close(results)
+
+	if err := <-sendDone; err != nil {
+		return err
+	}
+	return recvErr
+}
+
+func receiveStream(ctx context.Context, stream pb.BalanceService_ProcessStreamServer, queues []chan *domain.ProcessRequest, results chan<- *pb.ProcessStreamResponse) error {
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		domainReq, err := parseProcessRequest(req)
+		if err != nil {
+			results <- streamError(req.TxId, err)
+			continue
+		}
+
+		queue := queues[accountShard(domainReq.AccountID, len(queues))]
+		select {
+		case queue <- domainReq:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *Server) processStreamItem(ctx context.Context, req *domain.ProcessRequest) *pb.ProcessStreamResponse {
+	resp, err := s.service.Process(ctx, req)
+	if err != nil {
+		return streamError(req.TxID, mapDomainError(err))
+	}
+
+	return &pb.ProcessStreamResponse{
+		TxId:   req.TxID,
+		Result: &pb.ProcessStreamResponse_Response{Response: mapDomainProcessResponse(resp)},
+	}
+}
+
+func streamError(txID string, err error) *pb.ProcessStreamResponse {
+	st := status.Convert(err)
+	return &pb.ProcessStreamResponse{
+		TxId: txID,
+		Result: &pb.ProcessStreamResponse_Error{Error: &pb.BatchItemError{
+			Code:    int32(st.Code()),
+			Message: st.Message(),
+		}},
+	}
+}
+
+// accountShard maps an account to a worker, the same one for the whole stream.
+func accountShard(accountID uuid.UUID, shards int) int {
+	h := fnv.New32a()
+	_, _ = h.Write(accountID[:])
+	return int(h.Sum32() % uint32(shards))
+}
@@ -0,0 +1,101 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/domain"
+	pb "github.com/MaksimPozharskiy/grpc-balance-processor/proto/balance"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+type recordingService struct {
+	domain.BalanceService
+
+	mu      sync.Mutex
+	applied map[uuid.UUID][]string
+}
+
+func (r *recordingService) Process(ctx context.Context, req *domain.ProcessRequest) (*domain.ProcessResponse, error) {
+	time.Sleep(time.Millisecond)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.applied[req.AccountID] = append(r.applied[req.AccountID], req.TxID)
+
+	return &domain.ProcessResponse{TxID: req.TxID, Status: domain.StatusOK, Timestamp: time.Now()}, nil
+}
+
+type fakeProcessStream struct {
+	grpc.ServerStream
+
+	ctx      context.Context
+	requests []*pb.ProcessRequest
+
+	mu        sync.Mutex
+	responses []*pb.ProcessStreamResponse
+}
+
+func (f *fakeProcessStream) Context() context.Context {
+	return f.ctx
+}
+
+func (f *fakeProcessStream) Recv() (*pb.ProcessRequest, error) {
+	if len(f.requests) == 0 {
+		return nil, io.EOF
+	}
+	req := f.requests[0]
+	f.requests = f.requests[1:]
+	return req, nil
+}
+
+func (f *fakeProcessStream) Send(resp *pb.ProcessStreamResponse) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses = append(f.responses, resp)
+	return nil
+}
+
+func TestServer_ProcessStream_PerAccountOrder(t *testing.T) {
+	accounts := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+
+	stream := &fakeProcessStream{ctx: context.Background()}
+	want := make(map[uuid.UUID][]string)
+	for i := 0; i < 60; i++ {
+		accountID := accounts[i%len(accounts)]
+		txID := fmt.Sprintf("stream-%03d", i)
+		want[accountID] = append(want[accountID], txID)
+		stream.requests = append(stream.requests, &pb.ProcessRequest{
+			AccountId: accountID.String(),
+			Source:    pb.Source_SOURCE_GAME,
+			State:     pb.State_STATE_DEPOSIT,
+			Amount:    "1.00",
+			TxId:      txID,
+		})
+	}
+	// invalid requests are answered without ending the stream
+	stream.requests = append(stream.requests, &pb.ProcessRequest{TxId: "stream-invalid"})
+
+	service := &recordingService{applied: make(map[uuid.UUID][]string)}
+	require.NoError(t, NewServer(service).ProcessStream(stream))
+
+	assert.Equal(t, want, service.applied)
+	require.Len(t, stream.responses, 61)
+
+	var invalid *pb.ProcessStreamResponse
+	for _, resp := range stream.responses {
+		if resp.TxId == "stream-invalid" {
+			invalid = resp
+		}
+	}
+	require.NotNil(t, invalid)
+	assert.Equal(t, int32(codes.InvalidArgument), invalid.GetError().GetCode())
+}
@@ -1,6 +1,9 @@
 package transport
 
 import (
+	"time"
+
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/domain"
 	pb "github.com/MaksimPozharskiy/grpc-balance-processor/proto/balance"
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
@@ -24,6 +27,69 @@ func validateProcessRequest(req *pb.ProcessRequest) error {
 	if req.State == pb.State_STATE_UNSPECIFIED {
 		return status.Error(codes.InvalidArgument, "state is required")
 	}
+	if req.State != pb.State_STATE_DEPOSIT && req.State != pb.State_STATE_WITHDRAW {
+		return status.Error(codes.InvalidArgument, "state must be deposit or withdraw, use Reserve, Capture and Release for holds")
+	}
+	return nil
+}
+
+const maxBatchItems = 5000
+
+func validateBatchProcessRequest(req *pb.BatchProcessRequest) (domain.BatchMode, error) {
+	if req.Mode == pb.BatchMode_BATCH_MODE_UNSPECIFIED {
+		return 0, status.Error(codes.InvalidArgument, "mode is required")
+	}
+	if len(req.Items) == 0 {
+		return 0, status.Error(codes.InvalidArgument, "items are required")
+	}
+	if len(req.Items) > maxBatchItems {
+		return 0, status.Errorf(codes.InvalidArgument, "batch must have at most %d items", maxBatchItems)
+	}
+	return mapProtoBatchMode(req.Mode)
+}
+
+func validateTransferRequest(req *pb.TransferRequest) error {
+	if req.FromAccountId == "" {
+		return status.Error(codes.InvalidArgument, "from_account_id is required")
+	}
+	if req.ToAccountId == "" {
+		return status.Error(codes.InvalidArgument, "to_account_id is required")
+	}
+	if req.TxId == "" {
+		return status.Error(codes.InvalidArgument, "tx_id is required")
+	}
+	if req.Amount == "" {
+		return status.Error(codes.InvalidArgument, "amount is required")
+	}
+	if req.Source == pb.Source_SOURCE_UNSPECIFIED {
+		return status.Error(codes.InvalidArgument, "source is required")
+	}
+	return nil
+}
+
+func validateReserveRequest(req *pb.ReserveRequest) error {
+	if req.AccountId == "" {
+		return status.Error(codes.InvalidArgument, "account_id is required")
+	}
+	if req.TxId == "" {
+		return status.Error(codes.InvalidArgument, "tx_id is required")
+	}
+	if req.Amount == "" {
+		return status.Error(codes.InvalidArgument, "amount is required")
+	}
+	if req.Source == pb.Source_SOURCE_UNSPECIFIED {
+		return status.Error(codes.InvalidArgument, "source is required")
+	}
+	return nil
+}
+
+func validateHoldTxID(holdTxID string) error {
+	if holdTxID == "" {
+		return status.Error(codes.InvalidArgument, "hold_tx_id is required")
+	}
+	if len(holdTxID) > 128 {
+		return status.Error(codes.InvalidArgument, "hold_tx_id must be at most 128 characters")
+	}
 	return nil
 }
 
@@ -36,6 +102,9 @@ func validateAndParseAccountID(accountID string) (uuid.UUID, error) {
 	if err != nil {
 		return uuid.Nil, status.Error(codes.InvalidArgument, "invalid account_id format: must be valid UUID")
 	}
+	if domain.IsSystemAccount(parsed) {
+		return uuid.Nil, status.Error(codes.InvalidArgument, "account_id is reserved for a system account")
+	}
 
 	return parsed, nil
 }
@@ -61,7 +130,21 @@ func validateAndParseAmount(amount string) (decimal.Decimal, error) {
 	return parsed, nil
 }
 
+// validateTxID checks the tx_id of a new operation, which must not have a
+// form the service reserves for the operations it writes.
 func validateTxID(txID string) error {
+	if err := validateLookupTxID(txID); err != nil {
+		return err
+	}
+	if domain.IsReservedTxID(txID) {
+		return status.Error(codes.InvalidArgument, "tx_id must not start with cancel:: or expire:: or end with ::debit or ::credit")
+	}
+	return nil
+}
+
+// validateLookupTxID checks the tx_id of an existing operation, which may be
+// one the service wrote.
+func validateLookupTxID(txID string) error {
 	if txID == "" {
 		return status.Error(codes.InvalidArgument, "tx_id is required")
 	}
@@ -72,3 +155,42 @@ func validateTxID(txID string) error {
 
 	return nil
 }
+
+func validateListOperationsRequest(req *pb.ListOperationsRequest) error {
+	if req.PageSize < 0 {
+		return status.Error(codes.InvalidArgument, "page_size must not be negative")
+	}
+	if req.CreatedFrom != nil && req.CreatedTo != nil &&
+		!req.CreatedFrom.AsTime().Before(req.CreatedTo.AsTime()) {
+		return status.Error(codes.InvalidArgument, "created_from must be before created_to")
+	}
+	return nil
+}
+
+const maxCancelReasonLength = 256
+
+func validateCancelOperationRequest(req *pb.CancelOperationRequest) error {
+	if err := validateLookupTxID(req.TxId); err != nil {
+		return err
+	}
+	if req.Reason == "" {
+		return status.Error(codes.InvalidArgument, "reason is required")
+	}
+	if len(req.Reason) > maxCancelReasonLength {
+		return status.Errorf(codes.InvalidArgument, "reason must be at most %d characters", maxCancelReasonLength)
+	}
+	return nil
+}
+
+func validateGetBalanceAtRequest(req *pb.GetBalanceAtRequest) error {
+	if req.At == nil {
+		return status.Error(codes.InvalidArgument, "at is required")
+	}
+	if err := req.At.CheckValid(); err != nil {
+		return status.Error(codes.InvalidArgument, "invalid at timestamp")
+	}
+	if req.At.AsTime().After(time.Now()) {
+		return status.Error(codes.InvalidArgument, "at must not be in the future")
+	}
+	return nil
+}
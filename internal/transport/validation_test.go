@@ -0,0 +1,39 @@
+package transport
+
+import (
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestValidateTxID(t *testing.T) {
+	tests := []struct {
+		txID       string
+		wantNew    bool
+		wantLookup bool
+	}{
+		{"tx-1", true, true},
+		{"", false, false},
+		{strings.Repeat("x", 129), false, false},
+		// forms the service writes itself can be looked up but not created
+		{"cancel::tx-1", false, true},
+		{"expire::hold-1", false, true},
+		{"tx-1::debit", false, true},
+		{"tx-1::credit", false, true},
+	}
+
+	for _, tt := range tests {
+		err := validateTxID(tt.txID)
+		if (err == nil) != tt.wantNew {
+			t.Errorf("validateTxID(%q) = %v, want valid %v", tt.txID, err, tt.wantNew)
+		}
+		if err != nil && status.Code(err) != codes.InvalidArgument {
+			t.Errorf("validateTxID(%q) code = %v, want InvalidArgument", tt.txID, status.Code(err))
+		}
+		if err := validateLookupTxID(tt.txID); (err == nil) != tt.wantLookup {
+			t.Errorf("validateLookupTxID(%q) = %v, want valid %v", tt.txID, err, tt.wantLookup)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/domain"
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultRunsPageSize = 20
+	maxRunsPageSize     = 100
+)
+
+type AdminUsecase struct {
+	scheduler domain.SchedulerController
+}
+
+func NewAdminUsecase(scheduler domain.SchedulerController) *AdminUsecase {
+	return &AdminUsecase{
+		scheduler: scheduler,
+	}
+}
+
+func (u *AdminUsecase) GetSchedulerStatus(ctx context.Context) (*domain.SchedulerStatus, error) {
+	return u.scheduler.Status(ctx)
+}
+
+func (u *AdminUsecase) ListSchedulerRuns(ctx context.Context, req *domain.ListSchedulerRunsRequest) (*domain.ListSchedulerRunsResponse, error) {
+	var beforeID int64
+	if req.PageToken != "" {
+		id, err := domain.DecodeSchedulerRunCursor(req.PageToken)
+		if err != nil {
+			return nil, err
+		}
+		beforeID = id
+	}
+
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultRunsPageSize
+	}
+	if pageSize > maxRunsPageSize {
+		pageSize = maxRunsPageSize
+	}
+
+	// fetch one extra row to know whether there is a next page
+	runs, err := u.scheduler.ListRuns(ctx, beforeID, pageSize+1, req.IncludeOutcomes)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &domain.ListSchedulerRunsResponse{Runs: runs}
+	if len(runs) > pageSize {
+		resp.Runs = runs[:pageSize]
+		resp.NextPageToken = domain.EncodeSchedulerRunCursor(runs[pageSize-1].ID)
+	}
+
+	return resp, nil
+}
+
+func (u *AdminUsecase) PauseScheduler(ctx context.Context, req *domain.PauseSchedulerRequest) (*domain.SchedulerStatus, error) {
+	logger.FromContext(ctx).Info("pausing cancel scheduler", zap.String("reason", req.Reason))
+
+	if err := u.scheduler.Pause(ctx, req.Reason); err != nil {
+		return nil, err
+	}
+	return u.scheduler.Status(ctx)
+}
+
+func (u *AdminUsecase) ResumeScheduler(ctx context.Context) (*domain.SchedulerStatus, error) {
+	logger.FromContext(ctx).Info("resuming cancel scheduler")
+
+	if err := u.scheduler.Resume(ctx); err != nil {
+		return nil, err
+	}
+	return u.scheduler.Status(ctx)
+}
+
+func (u *AdminUsecase) TriggerRunNow(ctx context.Context) (*domain.SchedulerRun, error) {
+	logger.FromContext(ctx).Info("triggering cancellation cycle")
+
+	run, err := u.scheduler.RunNow(ctx)
+	if err != nil {
+		logger.FromContext(ctx).Warn("manual cancellation cycle rejected", zap.Error(err))
+		return nil, err
+	}
+	return run, nil
+}
@@ -0,0 +1,109 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockSchedulerController struct {
+	runs   []*domain.SchedulerRun
+	status *domain.SchedulerStatus
+	paused string
+	err    error
+}
+
+func (m *mockSchedulerController) Status(ctx context.Context) (*domain.SchedulerStatus, error) {
+	return m.status, m.err
+}
+
+func (m *mockSchedulerController) ListRuns(ctx context.Context, beforeID int64, limit int, withOutcomes bool) ([]*domain.SchedulerRun, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	var res []*domain.SchedulerRun
+	for _, run := range m.runs {
+		if beforeID > 0 && run.ID >= beforeID {
+			continue
+		}
+		if len(res) == limit {
+			break
+		}
+		res = append(res, run)
+	}
+	return res, nil
+}
+
+func (m *mockSchedulerController) Pause(ctx context.Context, reason string) error {
+	m.paused = reason
+	return m.err
+}
+
+func (m *mockSchedulerController) Resume(ctx context.Context) error {
+	return m.err
+}
+
+func (m *mockSchedulerController) RunNow(ctx context.Context) (*domain.SchedulerRun, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.runs[0], nil
+}
+
+func TestAdminUsecase_ListSchedulerRuns_Pagination(t *testing.T) {
+	var runs []*domain.SchedulerRun
+	for id := int64(5); id >= 1; id-- {
+		runs = append(runs, &domain.SchedulerRun{ID: id})
+	}
+
+	usecase := NewAdminUsecase(&mockSchedulerController{runs: runs})
+
+	var seen []int64
+	token := ""
+	for page := 0; page < 3; page++ {
+		resp, err := usecase.ListSchedulerRuns(context.Background(), &domain.ListSchedulerRunsRequest{
+			PageSize:  2,
+			PageToken: token,
+		})
+		require.NoError(t, err)
+
+		for _, run := range resp.Runs {
+			seen = append(seen, run.ID)
+		}
+		token = resp.NextPageToken
+		if token == "" {
+			break
+		}
+	}
+
+	assert.Equal(t, []int64{5, 4, 3, 2, 1}, seen)
+	assert.Empty(t, token)
+}
+
+func TestAdminUsecase_ListSchedulerRuns_InvalidToken(t *testing.T) {
+	usecase := NewAdminUsecase(&mockSchedulerController{})
+
+	_, err := usecase.ListSchedulerRuns(context.Background(), &domain.ListSchedulerRunsRequest{PageToken: "!!!"})
+	assert.ErrorIs(t, err, domain.ErrInvalidPageToken)
+}
+
+func TestAdminUsecase_PauseScheduler(t *testing.T) {
+	controller := &mockSchedulerController{status: &domain.SchedulerStatus{Paused: true, PauseReason: "incident"}}
+	usecase := NewAdminUsecase(controller)
+
+	status, err := usecase.PauseScheduler(context.Background(), &domain.PauseSchedulerRequest{Reason: "incident"})
+	require.NoError(t, err)
+	assert.Equal(t, "incident", controller.paused)
+	assert.True(t, status.Paused)
+}
+
+func TestAdminUsecase_TriggerRunNow_NotLeader(t *testing.T) {
+	usecase := NewAdminUsecase(&mockSchedulerController{err: domain.ErrNotSchedulerLeader})
+
+	_, err := usecase.TriggerRunNow(context.Background())
+	assert.ErrorIs(t, err, domain.ErrNotSchedulerLeader)
+}
@@ -3,82 +3,517 @@ package usecase
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/domain"
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
+var tracer = otel.Tracer("github.com/MaksimPozharskiy/grpc-balance-processor/internal/usecase")
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
 type BalanceUsecase struct {
-	repo domain.BalanceRepository
+	repo      domain.BalanceRepository
+	watcher   domain.BalanceWatcher
+	canceller domain.OperationCanceller
+	holdTTL   time.Duration
 }
 
-func NewBalanceUsecase(repo domain.BalanceRepository) *BalanceUsecase {
+func NewBalanceUsecase(repo domain.BalanceRepository, watcher domain.BalanceWatcher, canceller domain.OperationCanceller, holdTTL time.Duration) *BalanceUsecase {
 	return &BalanceUsecase{
-		repo: repo,
+		repo:      repo,
+		watcher:   watcher,
+		canceller: canceller,
+		holdTTL:   holdTTL,
 	}
 }
 
+// failSpan records err on span and returns it.
+func failSpan(span trace.Span, err error) error {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	return err
+}
+
 func (u *BalanceUsecase) Process(ctx context.Context, req *domain.ProcessRequest) (*domain.ProcessResponse, error) {
-	zap.L().Info("processing transaction",
+	ctx, span := tracer.Start(ctx, "BalanceUsecase.Process", trace.WithAttributes(
+		attribute.String("tx_id", req.TxID),
+		attribute.String("source", string(req.Source)),
+		attribute.String("state", string(req.State)),
+	))
+	defer span.End()
+
+	logger.FromContext(ctx).Info("processing transaction",
 		zap.String("tx_id", req.TxID),
 		zap.String("account_id", req.AccountID.String()),
 		zap.String("amount", req.Amount.String()),
 	)
 
-	op := &domain.Operation{
+	outcome, err := u.repo.ProcessTransaction(ctx, newOperation(req))
+	if err != nil {
+		failSpan(span, err)
+		if errors.Is(err, domain.ErrIdempotencyConflict) {
+			logger.FromContext(ctx).Warn("tx_id reused with a different payload", zap.String("tx_id", req.TxID))
+			return nil, err
+		}
+		logger.FromContext(ctx).Error("ProcessTransaction failed", zap.Error(err))
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.String("status", outcome.Status.String()))
+	countProcessed(req.Source, req.State, outcome)
+	return newProcessResponse(req.TxID, outcome), nil
+}
+
+func (u *BalanceUsecase) BatchProcess(ctx context.Context, req *domain.BatchProcessRequest) (*domain.BatchProcessResponse, error) {
+	ctx, span := tracer.Start(ctx, "BalanceUsecase.BatchProcess", trace.WithAttributes(
+		attribute.Int("items", len(req.Items)),
+		attribute.Int("mode", int(req.Mode)),
+	))
+	defer span.End()
+
+	logger.FromContext(ctx).Info("processing batch",
+		zap.Int("items", len(req.Items)),
+		zap.Int("mode", int(req.Mode)),
+	)
+
+	ops := make([]*domain.Operation, 0, len(req.Items))
+	for _, item := range req.Items {
+		ops = append(ops, newOperation(item))
+	}
+
+	results := make([]domain.BatchItemResult, len(ops))
+
+	if req.Mode == domain.BatchModeAllOrNothing {
+		outcomes, err := u.repo.ProcessBatchAtomic(ctx, ops)
+		if err != nil {
+			failSpan(span, err)
+			var itemErr *domain.BatchItemError
+			if errors.As(err, &itemErr) {
+				logger.FromContext(ctx).Warn("batch aborted", zap.Error(err))
+			} else {
+				logger.FromContext(ctx).Error("ProcessBatchAtomic failed", zap.Error(err))
+			}
+			return nil, err
+		}
+
+		for i, outcome := range outcomes {
+			countProcessed(ops[i].Source, ops[i].State, outcome)
+			results[i].Response = newProcessResponse(ops[i].TxID, outcome)
+		}
+		return &domain.BatchProcessResponse{Results: results}, nil
+	}
+
+	for i, item := range u.repo.ProcessBatch(ctx, ops) {
+		if item.Err != nil {
+			logger.FromContext(ctx).Warn("batch item failed", zap.String("tx_id", ops[i].TxID), zap.Error(item.Err))
+			results[i].Err = item.Err
+			continue
+		}
+		countProcessed(ops[i].Source, ops[i].State, item.Outcome)
+		results[i].Response = newProcessResponse(ops[i].TxID, item.Outcome)
+	}
+
+	return &domain.BatchProcessResponse{Results: results}, nil
+}
+
+func newOperation(req *domain.ProcessRequest) *domain.Operation {
+	return &domain.Operation{
 		TxID:      req.TxID,
 		AccountID: req.AccountID,
 		Source:    req.Source,
 		State:     req.State,
 		Amount:    req.Amount,
 	}
+}
+
+func newProcessResponse(txID string, outcome *domain.ProcessOutcome) *domain.ProcessResponse {
+	return &domain.ProcessResponse{
+		TxID:      txID,
+		Status:    outcome.Status,
+		Balance:   outcome.Balance,
+		Timestamp: outcome.ProcessedAt,
+		Replayed:  outcome.Replayed,
+	}
+}
+
+func (u *BalanceUsecase) Transfer(ctx context.Context, req *domain.TransferRequest) (*domain.TransferResponse, error) {
+	ctx, span := tracer.Start(ctx, "BalanceUsecase.Transfer", trace.WithAttributes(
+		attribute.String("tx_id", req.TxID),
+		attribute.String("from_account_id", req.FromAccountID.String()),
+		attribute.String("to_account_id", req.ToAccountID.String()),
+		attribute.String("source", string(req.Source)),
+	))
+	defer span.End()
 
-	account, err := u.repo.ProcessTransaction(ctx, op)
+	logger.FromContext(ctx).Info("processing transfer",
+		zap.String("tx_id", req.TxID),
+		zap.String("from_account_id", req.FromAccountID.String()),
+		zap.String("to_account_id", req.ToAccountID.String()),
+		zap.String("amount", req.Amount.String()),
+	)
+
+	t := &domain.Transfer{
+		TxID:          req.TxID,
+		FromAccountID: req.FromAccountID,
+		ToAccountID:   req.ToAccountID,
+		Source:        req.Source,
+		Amount:        req.Amount,
+	}
+
+	outcome, err := u.repo.Transfer(ctx, t)
 	if err != nil {
-		if errors.Is(err, domain.ErrDuplicateTx) {
-			return &domain.ProcessResponse{
-				TxID:      req.TxID,
-				Status:    domain.StatusAlreadyProcessed,
-				Balance:   account.Balance,
-				Timestamp: account.UpdatedAt,
-			}, nil
-		}
-		if errors.Is(err, domain.ErrNegativeBalance) {
-			currentAccount, getErr := u.repo.GetAccount(ctx, req.AccountID)
-			if getErr != nil {
-				return nil, getErr
-			}
-			return &domain.ProcessResponse{
-				TxID:      req.TxID,
-				Status:    domain.StatusRejectedNegative,
-				Balance:   currentAccount.Balance,
-				Timestamp: currentAccount.UpdatedAt,
-			}, nil
+		failSpan(span, err)
+		if errors.Is(err, domain.ErrIdempotencyConflict) {
+			logger.FromContext(ctx).Warn("tx_id reused with a different payload", zap.String("tx_id", req.TxID))
+			return nil, err
 		}
-		zap.L().Error("ProcessTransaction failed", zap.Error(err))
+		logger.FromContext(ctx).Error("Transfer failed", zap.Error(err))
 		return nil, err
 	}
 
-	return &domain.ProcessResponse{
-		TxID:      req.TxID,
-		Status:    domain.StatusOK,
-		Balance:   account.Balance,
-		Timestamp: account.UpdatedAt,
+	span.SetAttributes(attribute.String("status", outcome.Status.String()))
+	return &domain.TransferResponse{
+		TxID:        req.TxID,
+		Status:      outcome.Status,
+		FromBalance: outcome.FromBalance,
+		ToBalance:   outcome.ToBalance,
+		Timestamp:   outcome.ProcessedAt,
+		Replayed:    outcome.Replayed,
 	}, nil
 }
 
 func (u *BalanceUsecase) GetBalance(ctx context.Context, req *domain.GetBalanceRequest) (*domain.GetBalanceResponse, error) {
-	zap.L().Info("getting balance",
+	ctx, span := tracer.Start(ctx, "BalanceUsecase.GetBalance", trace.WithAttributes(
+		attribute.String("account_id", req.AccountID.String()),
+	))
+	defer span.End()
+
+	logger.FromContext(ctx).Info("getting balance",
 		zap.String("account_id", req.AccountID.String()),
 	)
 
 	account, err := u.repo.GetAccount(ctx, req.AccountID)
 	if err != nil {
-		return nil, err
+		return nil, failSpan(span, err)
 	}
 
 	return &domain.GetBalanceResponse{
 		Balance:   account.Balance,
+		Available: account.Available(),
 		UpdatedAt: account.UpdatedAt,
 	}, nil
 }
+
+func (u *BalanceUsecase) GetBalanceAt(ctx context.Context, req *domain.GetBalanceAtRequest) (*domain.GetBalanceAtResponse, error) {
+	ctx, span := tracer.Start(ctx, "BalanceUsecase.GetBalanceAt", trace.WithAttributes(
+		attribute.String("account_id", req.AccountID.String()),
+		attribute.String("at", req.At.UTC().Format(time.RFC3339Nano)),
+	))
+	defer span.End()
+
+	logger.FromContext(ctx).Info("getting balance at",
+		zap.String("account_id", req.AccountID.String()),
+		zap.Time("at", req.At),
+	)
+
+	// operations may still be written before a future moment
+	if req.At.After(time.Now()) {
+		return nil, failSpan(span, domain.ErrBalanceAtInFuture)
+	}
+
+	balance, err := u.repo.GetBalanceAt(ctx, req.AccountID, req.At)
+	if err != nil {
+		return nil, failSpan(span, err)
+	}
+
+	return &domain.GetBalanceAtResponse{
+		Balance: balance,
+		At:      req.At,
+	}, nil
+}
+
+// WatchBalance sends the current balance and then every committed change of
+// the account until ctx is done. A change may be reported twice around the
+// initial snapshot; each event carries the full balance.
+func (u *BalanceUsecase) WatchBalance(ctx context.Context, req *domain.WatchBalanceRequest, send func(*domain.BalanceEvent) error) error {
+	logger.FromContext(ctx).Info("watching balance",
+		zap.String("account_id", req.AccountID.String()),
+	)
+
+	// subscribe before reading the snapshot so no change falls in between
+	events, unsubscribe := u.watcher.Subscribe(req.AccountID)
+	defer unsubscribe()
+
+	account, err := u.repo.GetAccount(ctx, req.AccountID)
+	if err != nil {
+		return err
+	}
+	if err := send(domain.NewBalanceEvent(account, "")); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-events:
+			if !ok {
+				logger.FromContext(ctx).Warn("balance watch interrupted", zap.String("account_id", req.AccountID.String()))
+				return domain.ErrWatchInterrupted
+			}
+			if err := send(ev); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (u *BalanceUsecase) Reserve(ctx context.Context, req *domain.ReserveRequest) (*domain.HoldResponse, error) {
+	ctx, span := tracer.Start(ctx, "BalanceUsecase.Reserve", trace.WithAttributes(
+		attribute.String("tx_id", req.TxID),
+		attribute.String("account_id", req.AccountID.String()),
+		attribute.String("source", string(req.Source)),
+	))
+	defer span.End()
+
+	logger.FromContext(ctx).Info("reserving funds",
+		zap.String("tx_id", req.TxID),
+		zap.String("account_id", req.AccountID.String()),
+		zap.String("amount", req.Amount.String()),
+	)
+
+	op := &domain.Operation{
+		TxID:      req.TxID,
+		AccountID: req.AccountID,
+		Source:    req.Source,
+		State:     domain.StateHold,
+		Amount:    req.Amount,
+	}
+
+	outcome, err := u.repo.Reserve(ctx, op, u.holdTTL)
+	if err != nil {
+		return nil, failSpan(span, u.holdError(ctx, "Reserve", req.TxID, err))
+	}
+
+	span.SetAttributes(attribute.String("status", outcome.Status.String()))
+	return newHoldResponse(req.TxID, req.TxID, outcome), nil
+}
+
+func (u *BalanceUsecase) Capture(ctx context.Context, req *domain.CaptureRequest) (*domain.HoldResponse, error) {
+	ctx, span := tracer.Start(ctx, "BalanceUsecase.Capture", trace.WithAttributes(
+		attribute.String("tx_id", req.TxID),
+		attribute.String("hold_tx_id", req.HoldTxID),
+	))
+	defer span.End()
+
+	logger.FromContext(ctx).Info("capturing hold",
+		zap.String("tx_id", req.TxID),
+		zap.String("hold_tx_id", req.HoldTxID),
+		zap.String("amount", req.Amount.String()),
+	)
+
+	op := &domain.Operation{
+		TxID:   req.TxID,
+		Amount: req.Amount,
+	}
+
+	outcome, err := u.repo.Capture(ctx, req.HoldTxID, op)
+	if err != nil {
+		return nil, failSpan(span, u.holdError(ctx, "Capture", req.TxID, err))
+	}
+
+	span.SetAttributes(attribute.String("status", outcome.Status.String()))
+	return newHoldResponse(req.TxID, req.HoldTxID, outcome), nil
+}
+
+func (u *BalanceUsecase) Release(ctx context.Context, req *domain.ReleaseRequest) (*domain.HoldResponse, error) {
+	ctx, span := tracer.Start(ctx, "BalanceUsecase.Release", trace.WithAttributes(
+		attribute.String("tx_id", req.TxID),
+		attribute.String("hold_tx_id", req.HoldTxID),
+	))
+	defer span.End()
+
+	logger.FromContext(ctx).Info("releasing hold",
+		zap.String("tx_id", req.TxID),
+		zap.String("hold_tx_id", req.HoldTxID),
+	)
+
+	op := &domain.Operation{
+		TxID: req.TxID,
+	}
+
+	outcome, err := u.repo.Release(ctx, req.HoldTxID, op)
+	if err != nil {
+		return nil, failSpan(span, u.holdError(ctx, "Release", req.TxID, err))
+	}
+
+	span.SetAttributes(attribute.String("status", outcome.Status.String()))
+	return newHoldResponse(req.TxID, req.HoldTxID, outcome), nil
+}
+
+func (u *BalanceUsecase) holdError(ctx context.Context, method string, txID string, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrIdempotencyConflict):
+		logger.FromContext(ctx).Warn("tx_id reused with a different payload", zap.String("tx_id", txID))
+	case errors.Is(err, domain.ErrHoldNotFound),
+		errors.Is(err, domain.ErrHoldClosed),
+		errors.Is(err, domain.ErrCaptureExceedsHold):
+		logger.FromContext(ctx).Info(method+" rejected", zap.String("tx_id", txID), zap.Error(err))
+	default:
+		logger.FromContext(ctx).Error(method+" failed", zap.Error(err))
+	}
+	return err
+}
+
+func newHoldResponse(txID string, holdTxID string, outcome *domain.HoldOutcome) *domain.HoldResponse {
+	resp := &domain.HoldResponse{
+		TxID:      txID,
+		HoldTxID:  holdTxID,
+		Status:    outcome.Status,
+		Balance:   outcome.Balance,
+		Available: outcome.Available,
+		Timestamp: outcome.ProcessedAt,
+		Replayed:  outcome.Replayed,
+	}
+	if outcome.Hold != nil {
+		resp.ExpiresAt = outcome.Hold.ExpiresAt
+	}
+	return resp
+}
+
+func (u *BalanceUsecase) GetOperation(ctx context.Context, req *domain.GetOperationRequest) (*domain.GetOperationResponse, error) {
+	ctx, span := tracer.Start(ctx, "BalanceUsecase.GetOperation", trace.WithAttributes(
+		attribute.String("tx_id", req.TxID),
+	))
+	defer span.End()
+
+	logger.FromContext(ctx).Info("getting operation",
+		zap.String("tx_id", req.TxID),
+	)
+
+	op, compensation, err := u.operationWithCompensation(ctx, req.TxID)
+	if err != nil {
+		return nil, failSpan(span, err)
+	}
+
+	return &domain.GetOperationResponse{
+		Operation:    op,
+		Compensation: compensation,
+	}, nil
+}
+
+func (u *BalanceUsecase) CancelOperation(ctx context.Context, req *domain.CancelOperationRequest) (*domain.CancelOperationResponse, error) {
+	ctx, span := tracer.Start(ctx, "BalanceUsecase.CancelOperation", trace.WithAttributes(
+		attribute.String("tx_id", req.TxID),
+	))
+	defer span.End()
+
+	logger.FromContext(ctx).Info("canceling operation",
+		zap.String("tx_id", req.TxID),
+		zap.String("reason", req.Reason),
+	)
+
+	replayed, err := u.canceller.CancelOperation(ctx, req.TxID, req.Reason)
+	if err != nil {
+		failSpan(span, err)
+		switch {
+		case errors.Is(err, domain.ErrOperationNotFound),
+			errors.Is(err, domain.ErrOperationNotCancellable),
+			errors.Is(err, domain.ErrInsufficientFundsToCancel):
+			logger.FromContext(ctx).Info("CancelOperation rejected", zap.String("tx_id", req.TxID), zap.Error(err))
+		default:
+			logger.FromContext(ctx).Error("CancelOperation failed", zap.Error(err))
+		}
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.Bool("replayed", replayed))
+
+	op, compensation, err := u.operationWithCompensation(ctx, req.TxID)
+	if err != nil {
+		return nil, failSpan(span, err)
+	}
+
+	return &domain.CancelOperationResponse{
+		Operation:    op,
+		Compensation: compensation,
+		Replayed:     replayed,
+	}, nil
+}
+
+// operationWithCompensation looks up txID and its compensation. The tx_id of
+// a transfer resolves to the transfer's debit leg, which represents it.
+func (u *BalanceUsecase) operationWithCompensation(ctx context.Context, txID string) (*domain.Operation, *domain.Operation, error) {
+	op, err := u.repo.GetOperationByTxID(ctx, txID)
+	if errors.Is(err, domain.ErrOperationNotFound) {
+		op, err = u.repo.GetOperationByTxID(ctx, domain.TransferDebitTxID(txID))
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// compensating operation exists only if the original was canceled
+	compensation, err := u.repo.GetOperationByTxID(ctx, domain.CompensatingTxID(op.TxID))
+	if err != nil {
+		if !errors.Is(err, domain.ErrOperationNotFound) {
+			return nil, nil, err
+		}
+		compensation = nil
+	}
+
+	return op, compensation, nil
+}
+
+func (u *BalanceUsecase) ListOperations(ctx context.Context, req *domain.ListOperationsRequest) (*domain.ListOperationsResponse, error) {
+	ctx, span := tracer.Start(ctx, "BalanceUsecase.ListOperations", trace.WithAttributes(
+		attribute.String("account_id", req.Filter.AccountID.String()),
+		attribute.Int("page_size", req.PageSize),
+	))
+	defer span.End()
+
+	logger.FromContext(ctx).Info("listing operations",
+		zap.String("account_id", req.Filter.AccountID.String()),
+		zap.Int("page_size", req.PageSize),
+	)
+
+	var after *domain.OperationCursor
+	if req.PageToken != "" {
+		cursor, err := domain.DecodeOperationCursor(req.PageToken)
+		if err != nil {
+			return nil, failSpan(span, err)
+		}
+		after = cursor
+	}
+
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	// fetch one extra row to know whether there is a next page
+	ops, err := u.repo.ListOperations(ctx, req.Filter, after, pageSize+1)
+	if err != nil {
+		return nil, failSpan(span, err)
+	}
+
+	resp := &domain.ListOperationsResponse{Operations: ops}
+	if len(ops) > pageSize {
+		resp.Operations = ops[:pageSize]
+		resp.NextPageToken = domain.CursorFromOperation(ops[pageSize-1]).Encode()
+	}
+
+	return resp, nil
+}
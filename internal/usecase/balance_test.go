@@ -2,6 +2,7 @@ package usecase
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
@@ -12,39 +13,140 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+// balanceSnapshot is a balance_snapshots row of mockRepository.
+type balanceSnapshot struct {
+	takenAt time.Time
+	balance decimal.Decimal
+}
+
 type mockRepository struct {
-	account   *domain.Account
-	operation *domain.Operation
-	err       error
+	account    *domain.Account
+	operation  *domain.Operation
+	operations map[string]*domain.Operation
+	history    []*domain.Operation
+	snapshots  []balanceSnapshot
+	outcome    *domain.ProcessOutcome
+	transfer   *domain.TransferOutcome
+	hold       *domain.HoldOutcome
+	batch      []domain.BatchItemOutcome
+	err        error
 }
 
 func (m *mockRepository) GetAccount(ctx context.Context, accountID uuid.UUID) (*domain.Account, error) {
 	return m.account, m.err
 }
 
+// GetBalanceAt mirrors sqlSelectBalanceAt: the latest snapshot taken at or
+// before at, plus the applied operations created from then until at.
+func (m *mockRepository) GetBalanceAt(ctx context.Context, accountID uuid.UUID, at time.Time) (decimal.Decimal, error) {
+	if m.err != nil {
+		return decimal.Zero, m.err
+	}
+	if m.account == nil || m.account.ID != accountID {
+		return decimal.Zero, fmt.Errorf("get balance at: %w", domain.ErrNotFound)
+	}
+
+	var from time.Time
+	balance := decimal.Zero
+	for _, s := range m.snapshots {
+		if !s.takenAt.After(at) && s.takenAt.After(from) {
+			from, balance = s.takenAt, s.balance
+		}
+	}
+
+	for _, op := range m.history {
+		if op.AccountID != accountID || !op.Applied || op.CreatedAt.Before(from) || !op.CreatedAt.Before(at) {
+			continue
+		}
+		switch op.State {
+		case domain.StateDeposit:
+			balance = balance.Add(op.Amount)
+		case domain.StateWithdraw, domain.StateCapture:
+			balance = balance.Sub(op.Amount)
+		}
+	}
+	return balance, nil
+}
+
 func (m *mockRepository) CreateAccount(ctx context.Context, accountID uuid.UUID) error {
 	return m.err
 }
 
-func (m *mockRepository) ProcessTransaction(ctx context.Context, op *domain.Operation) (*domain.Account, error) {
-	return m.account, m.err
+func (m *mockRepository) ProcessTransaction(ctx context.Context, op *domain.Operation) (*domain.ProcessOutcome, error) {
+	return m.outcome, m.err
+}
+
+func (m *mockRepository) ProcessBatchAtomic(ctx context.Context, ops []*domain.Operation) ([]*domain.ProcessOutcome, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	outcomes := make([]*domain.ProcessOutcome, len(ops))
+	for i := range ops {
+		outcomes[i] = m.outcome
+	}
+	return outcomes, nil
+}
+
+func (m *mockRepository) ProcessBatch(ctx context.Context, ops []*domain.Operation) []domain.BatchItemOutcome {
+	return m.batch
+}
+
+func (m *mockRepository) Transfer(ctx context.Context, t *domain.Transfer) (*domain.TransferOutcome, error) {
+	return m.transfer, m.err
+}
+
+func (m *mockRepository) Reserve(ctx context.Context, op *domain.Operation, ttl time.Duration) (*domain.HoldOutcome, error) {
+	return m.hold, m.err
+}
+
+func (m *mockRepository) Capture(ctx context.Context, holdTxID string, op *domain.Operation) (*domain.HoldOutcome, error) {
+	return m.hold, m.err
+}
+
+func (m *mockRepository) Release(ctx context.Context, holdTxID string, op *domain.Operation) (*domain.HoldOutcome, error) {
+	return m.hold, m.err
 }
 
 func (m *mockRepository) GetOperationByTxID(ctx context.Context, txID string) (*domain.Operation, error) {
+	if m.operations != nil {
+		op, ok := m.operations[txID]
+		if !ok {
+			return nil, domain.ErrOperationNotFound
+		}
+		return op, nil
+	}
 	return m.operation, m.err
 }
 
+func (m *mockRepository) ListOperations(ctx context.Context, filter domain.OperationFilter, after *domain.OperationCursor, limit int) ([]*domain.Operation, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	var res []*domain.Operation
+	for _, op := range m.history {
+		if after != nil && op.ID >= after.ID {
+			continue
+		}
+		if len(res) == limit {
+			break
+		}
+		res = append(res, op)
+	}
+	return res, nil
+}
+
 func TestBalanceUsecase_Process_Deposit(t *testing.T) {
 	accountID := uuid.New()
 	mockRepo := &mockRepository{
-		account: &domain.Account{
-			ID:        accountID,
-			Balance:   decimal.NewFromFloat(110.50),
-			UpdatedAt: time.Now(),
+		outcome: &domain.ProcessOutcome{
+			Status:      domain.StatusOK,
+			Balance:     decimal.NewFromFloat(110.50),
+			ProcessedAt: time.Now(),
 		},
 	}
 
-	usecase := NewBalanceUsecase(mockRepo)
+	usecase := NewBalanceUsecase(mockRepo, nil, nil, time.Hour)
 
 	req := &domain.ProcessRequest{
 		AccountID: accountID,
@@ -58,6 +160,135 @@ func TestBalanceUsecase_Process_Deposit(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, domain.StatusOK, resp.Status)
 	assert.True(t, resp.Balance.Equal(decimal.NewFromFloat(110.50)))
+	assert.False(t, resp.Replayed)
+}
+
+func TestBalanceUsecase_Process_ReplayRejected(t *testing.T) {
+	processedAt := time.Date(2025, 1, 31, 23, 59, 0, 0, time.UTC)
+	mockRepo := &mockRepository{
+		outcome: &domain.ProcessOutcome{
+			Status:      domain.StatusRejectedNegative,
+			Balance:     decimal.NewFromFloat(3),
+			ProcessedAt: processedAt,
+			Replayed:    true,
+		},
+	}
+
+	usecase := NewBalanceUsecase(mockRepo, nil, nil, time.Hour)
+
+	req := &domain.ProcessRequest{
+		AccountID: uuid.New(),
+		Source:    domain.SourcePayment,
+		State:     domain.StateWithdraw,
+		Amount:    decimal.NewFromFloat(5),
+		TxID:      "test-tx-003",
+	}
+
+	resp, err := usecase.Process(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, domain.StatusRejectedNegative, resp.Status)
+	assert.True(t, resp.Balance.Equal(decimal.NewFromFloat(3)))
+	assert.Equal(t, processedAt, resp.Timestamp)
+	assert.True(t, resp.Replayed)
+}
+
+func TestBalanceUsecase_Process_IdempotencyConflict(t *testing.T) {
+	usecase := NewBalanceUsecase(&mockRepository{err: domain.ErrIdempotencyConflict}, nil, nil, time.Hour)
+
+	req := &domain.ProcessRequest{
+		AccountID: uuid.New(),
+		Source:    domain.SourcePayment,
+		State:     domain.StateDeposit,
+		Amount:    decimal.NewFromFloat(20),
+		TxID:      "test-tx-001",
+	}
+
+	resp, err := usecase.Process(context.Background(), req)
+	require.ErrorIs(t, err, domain.ErrIdempotencyConflict)
+	assert.Nil(t, resp)
+}
+
+func TestBalanceUsecase_BatchProcess_BestEffort(t *testing.T) {
+	mockRepo := &mockRepository{
+		batch: []domain.BatchItemOutcome{
+			{Outcome: &domain.ProcessOutcome{Status: domain.StatusOK, Balance: decimal.NewFromFloat(10)}},
+			{Err: domain.ErrIdempotencyConflict},
+			{Outcome: &domain.ProcessOutcome{Status: domain.StatusRejectedNegative, Balance: decimal.Zero}},
+		},
+	}
+
+	usecase := NewBalanceUsecase(mockRepo, nil, nil, time.Hour)
+
+	accountID := uuid.New()
+	req := &domain.BatchProcessRequest{Mode: domain.BatchModeBestEffort}
+	for _, txID := range []string{"batch-1", "batch-2", "batch-3"} {
+		req.Items = append(req.Items, &domain.ProcessRequest{
+			AccountID: accountID,
+			Source:    domain.SourcePayment,
+			State:     domain.StateDeposit,
+			Amount:    decimal.NewFromFloat(10),
+			TxID:      txID,
+		})
+	}
+
+	resp, err := usecase.BatchProcess(context.Background(), req)
+	require.NoError(t, err)
+	require.Len(t, resp.Results, 3)
+
+	assert.Equal(t, "batch-1", resp.Results[0].Response.TxID)
+	assert.Equal(t, domain.StatusOK, resp.Results[0].Response.Status)
+	require.ErrorIs(t, resp.Results[1].Err, domain.ErrIdempotencyConflict)
+	assert.Nil(t, resp.Results[1].Response)
+	assert.Equal(t, "batch-3", resp.Results[2].Response.TxID)
+	assert.Equal(t, domain.StatusRejectedNegative, resp.Results[2].Response.Status)
+}
+
+func TestBalanceUsecase_BatchProcess_AllOrNothingAborted(t *testing.T) {
+	itemErr := &domain.BatchItemError{Index: 1, TxID: "batch-2", Err: domain.ErrNegativeBalance}
+	usecase := NewBalanceUsecase(&mockRepository{err: itemErr}, nil, nil, time.Hour)
+
+	req := &domain.BatchProcessRequest{
+		Mode: domain.BatchModeAllOrNothing,
+		Items: []*domain.ProcessRequest{
+			{AccountID: uuid.New(), Source: domain.SourceGame, State: domain.StateDeposit, Amount: decimal.NewFromFloat(1), TxID: "batch-1"},
+			{AccountID: uuid.New(), Source: domain.SourceGame, State: domain.StateWithdraw, Amount: decimal.NewFromFloat(5), TxID: "batch-2"},
+		},
+	}
+
+	resp, err := usecase.BatchProcess(context.Background(), req)
+	assert.Nil(t, resp)
+
+	var gotErr *domain.BatchItemError
+	require.ErrorAs(t, err, &gotErr)
+	assert.Equal(t, 1, gotErr.Index)
+	require.ErrorIs(t, err, domain.ErrNegativeBalance)
+}
+
+func TestBalanceUsecase_Transfer(t *testing.T) {
+	mockRepo := &mockRepository{
+		transfer: &domain.TransferOutcome{
+			Status:      domain.StatusOK,
+			FromBalance: decimal.NewFromFloat(40),
+			ToBalance:   decimal.NewFromFloat(60),
+			ProcessedAt: time.Now(),
+		},
+	}
+
+	usecase := NewBalanceUsecase(mockRepo, nil, nil, time.Hour)
+
+	req := &domain.TransferRequest{
+		FromAccountID: uuid.New(),
+		ToAccountID:   uuid.New(),
+		Source:        domain.SourcePayment,
+		Amount:        decimal.NewFromFloat(10),
+		TxID:          "test-transfer-001",
+	}
+
+	resp, err := usecase.Transfer(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, domain.StatusOK, resp.Status)
+	assert.True(t, resp.FromBalance.Equal(decimal.NewFromFloat(40)))
+	assert.True(t, resp.ToBalance.Equal(decimal.NewFromFloat(60)))
 }
 
 func TestBalanceUsecase_GetBalance(t *testing.T) {
@@ -66,11 +297,12 @@ func TestBalanceUsecase_GetBalance(t *testing.T) {
 		account: &domain.Account{
 			ID:        accountID,
 			Balance:   decimal.NewFromFloat(25.75),
+			Held:      decimal.NewFromFloat(5.25),
 			UpdatedAt: time.Now(),
 		},
 	}
 
-	usecase := NewBalanceUsecase(mockRepo)
+	usecase := NewBalanceUsecase(mockRepo, nil, nil, time.Hour)
 
 	req := &domain.GetBalanceRequest{
 		AccountID: accountID,
@@ -79,4 +311,298 @@ func TestBalanceUsecase_GetBalance(t *testing.T) {
 	resp, err := usecase.GetBalance(context.Background(), req)
 	require.NoError(t, err)
 	assert.True(t, resp.Balance.Equal(decimal.NewFromFloat(25.75)))
+	assert.True(t, resp.Available.Equal(decimal.NewFromFloat(20.50)))
+}
+
+func TestBalanceUsecase_GetBalanceAt(t *testing.T) {
+	accountID := uuid.New()
+	day := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+	op := func(id int64, state domain.State, amount float64, at time.Duration) *domain.Operation {
+		return &domain.Operation{
+			ID:        id,
+			AccountID: accountID,
+			State:     state,
+			Amount:    decimal.NewFromFloat(amount),
+			CreatedAt: day.Add(at),
+			Applied:   true,
+		}
+	}
+
+	mockRepo := &mockRepository{
+		account: &domain.Account{ID: accountID},
+		// covers operations 1 and 2
+		snapshots: []balanceSnapshot{{takenAt: day.Add(12 * time.Hour), balance: decimal.NewFromFloat(70)}},
+		history: []*domain.Operation{
+			op(1, domain.StateDeposit, 100, time.Hour),
+			op(2, domain.StateWithdraw, 30, 2*time.Hour),
+			op(3, domain.StateDeposit, 15, 13*time.Hour),
+			op(4, domain.StateWithdraw, 5, 14*time.Hour),
+			{ID: 5, AccountID: accountID, State: domain.StateDeposit, Amount: decimal.NewFromFloat(1000), CreatedAt: day.Add(15 * time.Hour)},
+		},
+	}
+	usecase := NewBalanceUsecase(mockRepo, nil, nil, time.Hour)
+
+	tests := []struct {
+		name     string
+		at       time.Time
+		expected float64
+	}{
+		{name: "before any operation", at: day, expected: 0},
+		{name: "before the snapshot", at: day.Add(90 * time.Minute), expected: 100},
+		{name: "at the snapshot", at: day.Add(12 * time.Hour), expected: 70},
+		// operation 4 is created exactly at, so it is not counted
+		{name: "snapshot plus delta", at: day.Add(14 * time.Hour), expected: 85},
+		{name: "unapplied operations are not counted", at: day.Add(16 * time.Hour), expected: 80},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := usecase.GetBalanceAt(context.Background(), &domain.GetBalanceAtRequest{AccountID: accountID, At: tt.at})
+			require.NoError(t, err)
+			assert.True(t, resp.Balance.Equal(decimal.NewFromFloat(tt.expected)), "balance = %s, want %v", resp.Balance, tt.expected)
+			assert.Equal(t, tt.at, resp.At)
+		})
+	}
+}
+
+func TestBalanceUsecase_GetBalanceAt_Future(t *testing.T) {
+	accountID := uuid.New()
+	usecase := NewBalanceUsecase(&mockRepository{account: &domain.Account{ID: accountID}}, nil, nil, time.Hour)
+
+	_, err := usecase.GetBalanceAt(context.Background(), &domain.GetBalanceAtRequest{AccountID: accountID, At: time.Now().Add(time.Hour)})
+	require.ErrorIs(t, err, domain.ErrBalanceAtInFuture)
+}
+
+func TestBalanceUsecase_GetBalanceAt_UnknownAccount(t *testing.T) {
+	usecase := NewBalanceUsecase(&mockRepository{account: &domain.Account{ID: uuid.New()}}, nil, nil, time.Hour)
+
+	_, err := usecase.GetBalanceAt(context.Background(), &domain.GetBalanceAtRequest{AccountID: uuid.New(), At: time.Now().Add(-time.Hour)})
+	require.ErrorIs(t, err, domain.ErrNotFound)
+}
+
+type mockWatcher struct {
+	events       chan *domain.BalanceEvent
+	unsubscribed bool
+}
+
+func (m *mockWatcher) Subscribe(accountID uuid.UUID) (<-chan *domain.BalanceEvent, func()) {
+	return m.events, func() { m.unsubscribed = true }
+}
+
+func TestBalanceUsecase_WatchBalance(t *testing.T) {
+	accountID := uuid.New()
+	mockRepo := &mockRepository{
+		account: &domain.Account{ID: accountID, Balance: decimal.NewFromFloat(10), UpdatedAt: time.Now()},
+	}
+	watcher := &mockWatcher{events: make(chan *domain.BalanceEvent, 1)}
+	watcher.events <- &domain.BalanceEvent{AccountID: accountID, TxID: "test-tx-001", Balance: decimal.NewFromFloat(15)}
+	close(watcher.events) // listener lost its connection
+
+	usecase := NewBalanceUsecase(mockRepo, watcher, nil, time.Hour)
+
+	var sent []*domain.BalanceEvent
+	err := usecase.WatchBalance(context.Background(), &domain.WatchBalanceRequest{AccountID: accountID}, func(ev *domain.BalanceEvent) error {
+		sent = append(sent, ev)
+		return nil
+	})
+	require.ErrorIs(t, err, domain.ErrWatchInterrupted)
+	require.Len(t, sent, 2)
+	assert.Empty(t, sent[0].TxID)
+	assert.True(t, sent[0].Balance.Equal(decimal.NewFromFloat(10)))
+	assert.Equal(t, "test-tx-001", sent[1].TxID)
+	assert.True(t, watcher.unsubscribed)
+}
+
+func TestBalanceUsecase_Reserve(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour)
+	mockRepo := &mockRepository{
+		hold: &domain.HoldOutcome{
+			ProcessOutcome: domain.ProcessOutcome{
+				Status:    domain.StatusOK,
+				Balance:   decimal.NewFromFloat(100),
+				Available: decimal.NewFromFloat(70),
+			},
+			Hold: &domain.Hold{TxID: "test-hold-001", ExpiresAt: expiresAt},
+		},
+	}
+
+	usecase := NewBalanceUsecase(mockRepo, nil, nil, time.Hour)
+
+	req := &domain.ReserveRequest{
+		AccountID: uuid.New(),
+		Source:    domain.SourceGame,
+		Amount:    decimal.NewFromFloat(30),
+		TxID:      "test-hold-001",
+	}
+
+	resp, err := usecase.Reserve(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, domain.StatusOK, resp.Status)
+	assert.Equal(t, "test-hold-001", resp.HoldTxID)
+	assert.True(t, resp.Available.Equal(decimal.NewFromFloat(70)))
+	assert.Equal(t, expiresAt, resp.ExpiresAt)
+}
+
+func TestBalanceUsecase_Capture_HoldClosed(t *testing.T) {
+	usecase := NewBalanceUsecase(&mockRepository{err: domain.ErrHoldClosed}, nil, nil, time.Hour)
+
+	req := &domain.CaptureRequest{
+		HoldTxID: "test-hold-001",
+		TxID:     "test-capture-001",
+	}
+
+	_, err := usecase.Capture(context.Background(), req)
+	require.ErrorIs(t, err, domain.ErrHoldClosed)
+}
+
+func TestBalanceUsecase_GetOperation(t *testing.T) {
+	accountID := uuid.New()
+	original := &domain.Operation{
+		ID:        1,
+		TxID:      "test-tx-002",
+		AccountID: accountID,
+		Source:    domain.SourcePayment,
+		State:     domain.StateDeposit,
+		Amount:    decimal.NewFromFloat(15),
+		Applied:   true,
+	}
+	compensation := &domain.Operation{
+		ID:        2,
+		TxID:      domain.CompensatingTxID("test-tx-002"),
+		AccountID: accountID,
+		Source:    domain.SourceService,
+		State:     domain.StateWithdraw,
+		Amount:    decimal.NewFromFloat(15),
+		Applied:   true,
+	}
+
+	usecase := NewBalanceUsecase(&mockRepository{
+		operations: map[string]*domain.Operation{
+			original.TxID:     original,
+			compensation.TxID: compensation,
+		},
+	}, nil, nil, time.Hour)
+
+	resp, err := usecase.GetOperation(context.Background(), &domain.GetOperationRequest{TxID: "test-tx-002"})
+	require.NoError(t, err)
+	assert.Equal(t, original, resp.Operation)
+	assert.Equal(t, compensation, resp.Compensation)
+
+	resp, err = usecase.GetOperation(context.Background(), &domain.GetOperationRequest{TxID: compensation.TxID})
+	require.NoError(t, err)
+	assert.Equal(t, compensation, resp.Operation)
+	assert.Nil(t, resp.Compensation)
+
+	_, err = usecase.GetOperation(context.Background(), &domain.GetOperationRequest{TxID: "missing"})
+	require.ErrorIs(t, err, domain.ErrOperationNotFound)
+}
+
+func TestBalanceUsecase_GetOperation_Transfer(t *testing.T) {
+	transfer := &domain.Transfer{TxID: "transfer-001", FromAccountID: uuid.New(), ToAccountID: uuid.New(), Amount: decimal.NewFromFloat(5)}
+	debit, credit := transfer.Legs()
+	compensation := &domain.Operation{TxID: domain.CompensatingTxID(debit.TxID), State: domain.StateDeposit, Applied: true}
+
+	usecase := NewBalanceUsecase(&mockRepository{
+		operations: map[string]*domain.Operation{
+			debit.TxID:        debit,
+			credit.TxID:       credit,
+			compensation.TxID: compensation,
+		},
+	}, nil, nil, time.Hour)
+
+	// only the legs are stored; the transfer's tx_id finds the debit leg
+	resp, err := usecase.GetOperation(context.Background(), &domain.GetOperationRequest{TxID: transfer.TxID})
+	require.NoError(t, err)
+	assert.Equal(t, debit, resp.Operation)
+	assert.Equal(t, compensation, resp.Compensation)
+
+	resp, err = usecase.GetOperation(context.Background(), &domain.GetOperationRequest{TxID: credit.TxID})
+	require.NoError(t, err)
+	assert.Equal(t, credit, resp.Operation)
+	assert.Nil(t, resp.Compensation)
+}
+
+type mockCanceller struct {
+	replayed bool
+	reason   string
+	err      error
+}
+
+func (m *mockCanceller) CancelOperation(ctx context.Context, txID string, reason string) (bool, error) {
+	m.reason = reason
+	return m.replayed, m.err
+}
+
+func TestBalanceUsecase_CancelOperation(t *testing.T) {
+	original := &domain.Operation{TxID: "test-tx-003", State: domain.StateDeposit, Applied: true}
+	compensation := &domain.Operation{TxID: domain.CompensatingTxID("test-tx-003"), State: domain.StateWithdraw, Applied: true}
+	mockRepo := &mockRepository{
+		operations: map[string]*domain.Operation{
+			original.TxID:     original,
+			compensation.TxID: compensation,
+		},
+	}
+	canceller := &mockCanceller{replayed: true}
+
+	usecase := NewBalanceUsecase(mockRepo, nil, canceller, time.Hour)
+
+	resp, err := usecase.CancelOperation(context.Background(), &domain.CancelOperationRequest{TxID: "test-tx-003", Reason: "chargeback"})
+	require.NoError(t, err)
+	assert.Equal(t, "chargeback", canceller.reason)
+	assert.Equal(t, original, resp.Operation)
+	assert.Equal(t, compensation, resp.Compensation)
+	assert.True(t, resp.Replayed)
+}
+
+func TestBalanceUsecase_CancelOperation_InsufficientFunds(t *testing.T) {
+	canceller := &mockCanceller{err: domain.ErrInsufficientFundsToCancel}
+	usecase := NewBalanceUsecase(&mockRepository{}, nil, canceller, time.Hour)
+
+	_, err := usecase.CancelOperation(context.Background(), &domain.CancelOperationRequest{TxID: "test-tx-003", Reason: "chargeback"})
+	require.ErrorIs(t, err, domain.ErrInsufficientFundsToCancel)
+}
+
+func TestBalanceUsecase_ListOperations_Pagination(t *testing.T) {
+	accountID := uuid.New()
+	now := time.Now()
+
+	var history []*domain.Operation
+	for id := int64(5); id >= 1; id-- {
+		history = append(history, &domain.Operation{
+			ID:        id,
+			AccountID: accountID,
+			CreatedAt: now.Add(time.Duration(id) * time.Second),
+		})
+	}
+
+	usecase := NewBalanceUsecase(&mockRepository{history: history}, nil, nil, time.Hour)
+	filter := domain.OperationFilter{AccountID: accountID}
+
+	var seen []int64
+	token := ""
+	for page := 0; page < 3; page++ {
+		resp, err := usecase.ListOperations(context.Background(), &domain.ListOperationsRequest{
+			Filter:    filter,
+			PageSize:  2,
+			PageToken: token,
+		})
+		require.NoError(t, err)
+
+		for _, op := range resp.Operations {
+			seen = append(seen, op.ID)
+		}
+		token = resp.NextPageToken
+		if token == "" {
+			break
+		}
+	}
+
+	assert.Equal(t, []int64{5, 4, 3, 2, 1}, seen)
+	assert.Empty(t, token)
+
+	_, err := usecase.ListOperations(context.Background(), &domain.ListOperationsRequest{
+		Filter:    filter,
+		PageToken: "not-a-token",
+	})
+	require.ErrorIs(t, err, domain.ErrInvalidPageToken)
 }
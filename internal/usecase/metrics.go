@@ -0,0 +1,24 @@
+package usecase
+
+import (
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/domain"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var processedOperations = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "balance",
+	Subsystem: "process",
+	Name:      "operations_total",
+	Help:      "Processed operations by source, state and status (ok, already_processed, rejected_negative).",
+}, []string{"source", "state", "status"})
+
+// countProcessed counts one processed operation; a replayed request is
+// counted as already_processed rather than under its original status.
+func countProcessed(source domain.Source, state domain.State, outcome *domain.ProcessOutcome) {
+	status := outcome.Status
+	if outcome.Replayed {
+		status = domain.StatusAlreadyProcessed
+	}
+	processedOperations.WithLabelValues(string(source), string(state), status.String()).Inc()
+}
@@ -0,0 +1,57 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/domain"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountProcessed_Replay(t *testing.T) {
+	source, state := domain.SourceService, domain.StateDeposit
+	ok := processedOperations.WithLabelValues(string(source), string(state), "ok")
+	replayed := processedOperations.WithLabelValues(string(source), string(state), "already_processed")
+	okBefore, replayedBefore := testutil.ToFloat64(ok), testutil.ToFloat64(replayed)
+
+	req := &domain.ProcessRequest{
+		AccountID: uuid.New(),
+		Source:    source,
+		State:     state,
+		Amount:    decimal.NewFromFloat(5),
+		TxID:      "metrics-tx-1",
+	}
+
+	usecase := NewBalanceUsecase(&mockRepository{
+		outcome: &domain.ProcessOutcome{Status: domain.StatusOK, ProcessedAt: time.Now()},
+	}, nil, nil, time.Hour)
+	_, err := usecase.Process(context.Background(), req)
+	require.NoError(t, err)
+
+	usecase = NewBalanceUsecase(&mockRepository{
+		outcome: &domain.ProcessOutcome{Status: domain.StatusOK, ProcessedAt: time.Now(), Replayed: true},
+	}, nil, nil, time.Hour)
+	resp, err := usecase.Process(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, domain.StatusOK, resp.Status)
+
+	usecase = NewBalanceUsecase(&mockRepository{
+		batch: []domain.BatchItemOutcome{
+			{Outcome: &domain.ProcessOutcome{Status: domain.StatusOK}},
+			{Outcome: &domain.ProcessOutcome{Status: domain.StatusOK, Replayed: true}},
+		},
+	}, nil, nil, time.Hour)
+	_, err = usecase.BatchProcess(context.Background(), &domain.BatchProcessRequest{
+		Mode:  domain.BatchModeBestEffort,
+		Items: []*domain.ProcessRequest{req, req},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(ok)-okBefore)
+	assert.Equal(t, 2.0, testutil.ToFloat64(replayed)-replayedBefore)
+}
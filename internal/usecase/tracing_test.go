@@ -0,0 +1,69 @@
+package usecase
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MaksimPozharskiy/grpc-balance-processor/internal/domain"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// spanRecorder is installed as the global provider once: the package
+// tracer delegates to the first global provider that is set.
+var spanRecorder = sync.OnceValue(func() *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	return recorder
+})
+
+func TestBalanceUsecase_Spans(t *testing.T) {
+	recorder := spanRecorder()
+	before := len(recorder.Ended())
+
+	accountID := uuid.New()
+	mockRepo := &mockRepository{
+		account:    &domain.Account{ID: accountID, Balance: decimal.NewFromFloat(10)},
+		transfer:   &domain.TransferOutcome{Status: domain.StatusOK},
+		operations: map[string]*domain.Operation{},
+	}
+	usecase := NewBalanceUsecase(mockRepo, nil, nil, time.Hour)
+	ctx := context.Background()
+
+	_, err := usecase.Transfer(ctx, &domain.TransferRequest{FromAccountID: accountID, ToAccountID: uuid.New(), TxID: "span-tx-1"})
+	require.NoError(t, err)
+	_, err = usecase.GetBalance(ctx, &domain.GetBalanceRequest{AccountID: accountID})
+	require.NoError(t, err)
+	_, err = usecase.GetBalanceAt(ctx, &domain.GetBalanceAtRequest{AccountID: accountID, At: time.Now().Add(time.Hour)})
+	require.ErrorIs(t, err, domain.ErrBalanceAtInFuture)
+	_, err = usecase.GetOperation(ctx, &domain.GetOperationRequest{TxID: "span-tx-2"})
+	require.ErrorIs(t, err, domain.ErrOperationNotFound)
+
+	spans := recorder.Ended()[before:]
+	require.Len(t, spans, 4)
+
+	want := []struct {
+		name  string
+		attr  attribute.KeyValue
+		error bool
+	}{
+		{"BalanceUsecase.Transfer", attribute.String("tx_id", "span-tx-1"), false},
+		{"BalanceUsecase.GetBalance", attribute.String("account_id", accountID.String()), false},
+		{"BalanceUsecase.GetBalanceAt", attribute.String("account_id", accountID.String()), true},
+		{"BalanceUsecase.GetOperation", attribute.String("tx_id", "span-tx-2"), true},
+	}
+	for i, w := range want {
+		assert.Equal(t, w.name, spans[i].Name())
+		assert.Contains(t, spans[i].Attributes(), w.attr, w.name)
+		assert.Equal(t, w.error, spans[i].Status().Code == codes.Error, w.name)
+	}
+}
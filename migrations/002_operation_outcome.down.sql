@@ -0,0 +1,6 @@
+ALTER TABLE operations
+  DROP COLUMN IF EXISTS processed_at,
+  DROP COLUMN IF EXISTS balance_after,
+  DROP COLUMN IF EXISTS status;
+
+DROP TYPE IF EXISTS status_t;
@@ -0,0 +1,8 @@
+CREATE TYPE status_t AS ENUM ('ok','rejected_negative');
+
+-- outcome of the first processing attempt, replayed for retries of the same tx_id;
+-- NULL for rows written before outcomes were stored and for compensating operations
+ALTER TABLE operations
+  ADD COLUMN IF NOT EXISTS status        status_t,
+  ADD COLUMN IF NOT EXISTS balance_after NUMERIC(20,2),
+  ADD COLUMN IF NOT EXISTS processed_at  timestamptz;
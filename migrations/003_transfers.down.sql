@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_ops_transfer;
+
+ALTER TABLE operations DROP COLUMN IF EXISTS transfer_id;
@@ -0,0 +1,4 @@
+-- both legs of a transfer carry the client tx_id of the transfer
+ALTER TABLE operations ADD COLUMN IF NOT EXISTS transfer_id text;
+
+CREATE INDEX IF NOT EXISTS idx_ops_transfer ON operations(transfer_id) WHERE transfer_id IS NOT NULL;
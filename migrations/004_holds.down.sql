@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_holds_open_expiry;
+DROP TABLE IF EXISTS holds;
+
+ALTER TABLE operations DROP COLUMN IF EXISTS available_after;
+
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS held_within_balance;
+ALTER TABLE accounts DROP COLUMN IF EXISTS held;
+
+-- enum values cannot be dropped; 'hold', 'capture' and 'release' stay in state_t
@@ -0,0 +1,21 @@
+ALTER TYPE state_t ADD VALUE IF NOT EXISTS 'hold';
+ALTER TYPE state_t ADD VALUE IF NOT EXISTS 'capture';
+ALTER TYPE state_t ADD VALUE IF NOT EXISTS 'release';
+
+-- funds reserved by open holds; available balance is balance - held
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS held NUMERIC(20,2) NOT NULL DEFAULT 0;
+ALTER TABLE accounts ADD CONSTRAINT held_within_balance CHECK (held >= 0 AND held <= balance);
+
+ALTER TABLE operations ADD COLUMN IF NOT EXISTS available_after NUMERIC(20,2);
+
+CREATE TABLE IF NOT EXISTS holds (
+  tx_id        text PRIMARY KEY REFERENCES operations(tx_id),
+  account_id   uuid NOT NULL REFERENCES accounts(id),
+  amount       NUMERIC(20,2) NOT NULL CHECK (amount > 0),
+  captured     NUMERIC(20,2) NOT NULL DEFAULT 0 CHECK (captured >= 0 AND captured <= amount),
+  expires_at   timestamptz NOT NULL,
+  closed_at    timestamptz,
+  closed_by    text
+);
+
+CREATE INDEX IF NOT EXISTS idx_holds_open_expiry ON holds(expires_at) WHERE closed_at IS NULL;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS balance_snapshots;
+DROP FUNCTION IF EXISTS operation_delta(state_t, numeric);
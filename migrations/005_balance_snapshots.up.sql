@@ -0,0 +1,19 @@
+-- signed effect of an applied operation on accounts.balance;
+-- hold and release only move funds in and out of accounts.held
+CREATE OR REPLACE FUNCTION operation_delta(st state_t, amount numeric) RETURNS numeric
+LANGUAGE sql IMMUTABLE AS $$
+  SELECT CASE st
+           WHEN 'deposit'  THEN amount
+           WHEN 'withdraw' THEN -amount
+           WHEN 'capture'  THEN -amount
+           ELSE 0
+         END
+$$;
+
+-- balance of an account from operations created before taken_at
+CREATE TABLE IF NOT EXISTS balance_snapshots (
+  account_id uuid NOT NULL REFERENCES accounts(id),
+  taken_at   timestamptz NOT NULL,
+  balance    NUMERIC(20,2) NOT NULL,
+  PRIMARY KEY (account_id, taken_at)
+);
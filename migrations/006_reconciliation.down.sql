@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS reconciliation_drifts;
+DROP INDEX IF EXISTS idx_reconciliation_runs_started;
+DROP TABLE IF EXISTS reconciliation_runs;
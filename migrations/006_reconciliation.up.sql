@@ -0,0 +1,22 @@
+-- one row per reconciliation of accounts.balance against the operations ledger
+CREATE TABLE IF NOT EXISTS reconciliation_runs (
+  id               bigserial PRIMARY KEY,
+  started_at       timestamptz NOT NULL,
+  finished_at      timestamptz NOT NULL DEFAULT now(),
+  status           text NOT NULL CHECK (status IN ('ok','drift','failed')),
+  accounts_checked integer NOT NULL DEFAULT 0,
+  drifted_accounts integer NOT NULL DEFAULT 0,
+  error            text
+);
+
+CREATE INDEX IF NOT EXISTS idx_reconciliation_runs_started ON reconciliation_runs(started_at DESC);
+
+-- accounts whose stored balance differs from the ledger; drift = stored - ledger
+CREATE TABLE IF NOT EXISTS reconciliation_drifts (
+  run_id         bigint NOT NULL REFERENCES reconciliation_runs(id) ON DELETE CASCADE,
+  account_id     uuid NOT NULL REFERENCES accounts(id),
+  stored_balance NUMERIC(20,2) NOT NULL,
+  ledger_balance NUMERIC(20,2) NOT NULL,
+  drift          NUMERIC(20,2) NOT NULL,
+  PRIMARY KEY (run_id, account_id)
+);
@@ -0,0 +1,8 @@
+DROP TRIGGER IF EXISTS ledger_entries_balanced ON ledger_entries;
+DROP FUNCTION IF EXISTS ledger_tx_balanced();
+
+DROP INDEX IF EXISTS idx_ledger_account_created;
+DROP INDEX IF EXISTS idx_ledger_tx;
+DROP TABLE IF EXISTS ledger_entries;
+
+DROP TABLE IF EXISTS system_accounts;
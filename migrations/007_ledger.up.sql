@@ -0,0 +1,72 @@
+-- counter-accounts money comes from and goes to, one per source;
+-- ids match domain.SystemAccountID
+CREATE TABLE IF NOT EXISTS system_accounts (
+  id     uuid PRIMARY KEY,
+  source source_t UNIQUE NOT NULL
+);
+
+INSERT INTO system_accounts (id, source) VALUES
+  ('00000000-0000-0000-0000-000000000001', 'game'),
+  ('00000000-0000-0000-0000-000000000002', 'payment'),
+  ('00000000-0000-0000-0000-000000000003', 'service')
+ON CONFLICT DO NOTHING;
+
+-- double-entry postings; accounts.balance is a cached projection of the user side
+CREATE TABLE IF NOT EXISTS ledger_entries (
+  id         bigserial PRIMARY KEY,
+  tx_id      text NOT NULL REFERENCES operations(tx_id),
+  account_id uuid NOT NULL,
+  amount     NUMERIC(20,2) NOT NULL CHECK (amount <> 0),
+  created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_tx ON ledger_entries(tx_id);
+CREATE INDEX IF NOT EXISTS idx_ledger_account_created ON ledger_entries(account_id, created_at);
+
+-- entries of a transaction must sum to zero when it commits
+CREATE OR REPLACE FUNCTION ledger_tx_balanced() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+  IF (SELECT sum(amount) FROM ledger_entries WHERE tx_id = NEW.tx_id) <> 0 THEN
+    RAISE EXCEPTION 'ledger entries of tx_id % do not sum to zero', NEW.tx_id;
+  END IF;
+  RETURN NULL;
+END
+$$;
+
+-- the row trigger would re-sum a tx for every backfilled entry: drop it
+-- for the backfill and check the whole backfill once afterwards
+DROP TRIGGER IF EXISTS ledger_entries_balanced ON ledger_entries;
+
+-- post existing applied operations against the system account of their source;
+-- a cancel:: compensation goes back to the source of the operation it reverses
+INSERT INTO ledger_entries (tx_id, account_id, amount, created_at)
+SELECT o.tx_id, o.account_id, operation_delta(o.state, o.amount), o.created_at
+  FROM operations o
+ WHERE o.applied AND operation_delta(o.state, o.amount) <> 0
+UNION ALL
+SELECT o.tx_id, s.id, -operation_delta(o.state, o.amount), o.created_at
+  FROM operations o
+  LEFT JOIN operations orig ON o.tx_id = 'cancel::' || orig.tx_id
+  JOIN system_accounts s ON s.source = COALESCE(orig.source, o.source)
+ WHERE o.applied AND operation_delta(o.state, o.amount) <> 0;
+
+DO $$
+DECLARE
+  unbalanced text;
+BEGIN
+  SELECT tx_id INTO unbalanced
+    FROM ledger_entries
+   GROUP BY tx_id
+  HAVING sum(amount) <> 0
+   LIMIT 1;
+  IF unbalanced IS NOT NULL THEN
+    RAISE EXCEPTION 'ledger entries of tx_id % do not sum to zero', unbalanced;
+  END IF;
+END
+$$;
+
+CREATE CONSTRAINT TRIGGER ledger_entries_balanced
+  AFTER INSERT ON ledger_entries
+  DEFERRABLE INITIALLY DEFERRED
+  FOR EACH ROW EXECUTE FUNCTION ledger_tx_balanced();
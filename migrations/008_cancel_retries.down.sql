@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_cancel_retries_parked;
+DROP INDEX IF EXISTS idx_cancel_retries_due;
+DROP TABLE IF EXISTS cancel_retries;
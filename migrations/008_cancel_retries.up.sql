@@ -0,0 +1,15 @@
+-- cancellations that were skipped for insufficient funds or failed, retried with backoff;
+-- parked_at is set once max attempts are used up and the operation awaits manual review
+CREATE TABLE IF NOT EXISTS cancel_retries (
+  operation_id    bigint PRIMARY KEY REFERENCES operations(id),
+  tx_id           text NOT NULL,
+  attempts        integer NOT NULL CHECK (attempts > 0),
+  last_reason     text NOT NULL CHECK (last_reason IN ('insufficient_funds','failed')),
+  next_attempt_at timestamptz NOT NULL,
+  parked_at       timestamptz,
+  created_at      timestamptz NOT NULL DEFAULT now(),
+  updated_at      timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_cancel_retries_due ON cancel_retries(next_attempt_at) WHERE parked_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_cancel_retries_parked ON cancel_retries(parked_at) WHERE parked_at IS NOT NULL;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS scheduler_state;
+DROP TABLE IF EXISTS scheduler_run_outcomes;
+DROP INDEX IF EXISTS idx_scheduler_runs_running;
+DROP TABLE IF EXISTS scheduler_runs;
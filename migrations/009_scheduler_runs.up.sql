@@ -0,0 +1,39 @@
+-- one row per cancellation cycle; holder is the replica that held the leader lock
+CREATE TABLE IF NOT EXISTS scheduler_runs (
+  id             bigserial PRIMARY KEY,
+  started_at     timestamptz NOT NULL DEFAULT now(),
+  finished_at    timestamptz,
+  holder         text NOT NULL,
+  trigger        text NOT NULL CHECK (trigger IN ('schedule','manual')),
+  status         text NOT NULL CHECK (status IN ('running','completed','interrupted','abandoned')),
+  candidates     integer NOT NULL DEFAULT 0,
+  cancelled      integer NOT NULL DEFAULT 0,
+  skipped        integer NOT NULL DEFAULT 0,
+  failed         integer NOT NULL DEFAULT 0,
+  not_applicable integer NOT NULL DEFAULT 0,
+  parked         integer NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduler_runs_running ON scheduler_runs(id) WHERE status = 'running';
+
+-- what a cycle did with each candidate, written as it goes
+CREATE TABLE IF NOT EXISTS scheduler_run_outcomes (
+  run_id       bigint NOT NULL REFERENCES scheduler_runs(id) ON DELETE CASCADE,
+  operation_id bigint NOT NULL,
+  tx_id        text NOT NULL,
+  result       text NOT NULL CHECK (result IN ('cancelled','skipped','failed','not_applicable')),
+  parked       boolean NOT NULL DEFAULT FALSE,
+  attempted_at timestamptz NOT NULL DEFAULT now(),
+  PRIMARY KEY (run_id, operation_id)
+);
+
+-- shared by all replicas, so a pause holds whoever becomes leader
+CREATE TABLE IF NOT EXISTS scheduler_state (
+  id           boolean PRIMARY KEY DEFAULT TRUE CHECK (id),
+  paused       boolean NOT NULL DEFAULT FALSE,
+  paused_at    timestamptz,
+  pause_reason text,
+  updated_at   timestamptz NOT NULL DEFAULT now()
+);
+
+INSERT INTO scheduler_state (id) VALUES (TRUE) ON CONFLICT (id) DO NOTHING;